@@ -30,7 +30,8 @@ func Run(token string) {
 		Then("name", stageName, tb.OnText).
 		Then("phone", stagePhone, tb.OnContact).
 		Then("share_location", stageShareLocation, tb.OnText).
-		Then("location", stageLocation, tb.OnLocation)
+		Then("location", stageLocation, tb.OnLocation).
+		ThenRating("feedback", &chain.Rating{Comment: true, Prompt: "Anything to add?", Handler: stageFeedback})
 
 	btnSharePhone := tb.ReplyButton{
 		Contact: true,
@@ -115,6 +116,15 @@ func stageShareLocation(e *chain.Node, c *tb.Message) *chain.Node {
 
 func stageLocation(e *chain.Node, c *tb.Message) *chain.Node {
 	log.Println(c.Sender.Recipient(), "goes through", e.GetId())
-	e.GetFlow().GetBot().Send(c.Sender, "You are all set now!")
+	if err := e.Next().AskRating(c.Sender, "You are all set now! How do you like me?"); err != nil {
+		log.Println(err)
+		return nil
+	}
+	return e.Next()
+}
+
+func stageFeedback(e *chain.Node, of *tb.User, rating int, comment string) *chain.Node {
+	log.Println(of.Recipient(), "rated", rating, comment)
+	e.GetFlow().GetBot().Send(of, "Thank you for the feedback!")
 	return nil // only return nil when it's over
 }
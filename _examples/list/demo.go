@@ -26,10 +26,11 @@ func Run(token string) {
 		panic(err)
 	}
 
-	list.Build("en")
+	list.SetDefaultLocale("en").BuildAll()
 
 	b.Handle("/start", func(m *tb.Message) {
-		if err := list.Start(m.Sender, "hello", "en"); err != nil {
+		// an empty language picks the user's language automatically
+		if err := list.Start(m.Sender, "hello", ""); err != nil {
 			log.Println(err)
 		}
 	})
@@ -9,7 +9,14 @@ import (
 	"time"
 )
 
-var total = 0
+// prices are shared by every user, carts are kept per user by the menu
+var prices = map[string]int{
+	"margarita": 10,
+	"pepperoni": 10,
+	"temaki":    5,
+	"nigiri":    5,
+	"sasazushi": 5,
+}
 
 func Run(token string) {
 	b, err := tb.NewBot(tb.Settings{
@@ -30,6 +37,8 @@ func Run(token string) {
 	if err != nil {
 		panic(err)
 	}
+	flow.SetDefaultLocale(defaultLocale).SetOwnerOnly(true) // menus shared into groups answer only to their owners
+	flow.SetTypeToSearch(true)                              // typing e.g. "pep" while the menu is open finds pepperoni
 	/*
 
 					An example of a menu
@@ -43,35 +52,67 @@ func Run(token string) {
 									 <-back
 						 	<-back
 		---------------------------------------------
-			invoice -> show the total
+			booking -> pick a date in a calendar
+		---------------------------------------------
+			invoice -> review the cart and check out
+		---------------------------------------------
+			status -> a live order status
 		---------------------------------------------
 			language -> switch the language
 		---------------------------------------------
 
 	*/
+	cart := flow.NewCart("order", userCheckout).SetMaxQuantity(10)
 	flow.GetRoot().
 		Add("greetings", userPressGreeting).
 		AddWith("order", userPress,
 			flow.NewNode("pizza", userPress).
-				Add("margarita", userOrderPizza).
-				Add("pepperoni", userOrderPizza).
+				AddItem(cart, "margarita").
+				AddItem(cart, "pepperoni").
 				Add("back", userPressBack), // traditional way of making back buttons
 			flow.NewNode("sushi", userPress).
-				Add("temaki", userOrderSushi).
-				Add("nigiri", userOrderSushi).
-				Add("sasazushi", userOrderSushi).
+				AddItem(cart, "temaki").
+				AddItem(cart, "nigiri").
+				AddItem(cart, "sasazushi").
 				Add("back", userPressBack),
 			flow.NewBackNode("back"), // a short hand for making back buttons
 		).
-		Add("invoice", userPressInvoice).
-		Add("language", userPressLanguage).GetFlow().Build("en").Build("ru")
+		AddManySub([]*menu.Node{
+			flow.NewCalendarNode("booking", &menu.Calendar{
+				Min:     time.Now(),
+				Max:     time.Now().AddDate(0, 2, 0),
+				Handler: userPickDate,
+			}).Add("back", userPressBack),
+			cart.NewSummaryNode("invoice").Add("back", userPressBack),
+			// the status page refreshes itself every 5 seconds while the user is on it
+			flow.NewNode("status", userPress).SetLive(5*time.Second, orderStatus).Add("back", userPressBack),
+		}).
+		Add("language", userPressLanguage).GetFlow().BuildAll()
+
+	if err := flow.Validate(); err != nil {
+		log.Println(err)
+	}
 
-	b.Handle("/start", func(m *tb.Message) {
-		err = flow.Start(m.Sender, "Hello there", defaultLocale)
-		if err != nil {
-			log.Println("failed to display the menu", err)
+	// "/start flow1-order-pizza" opens the menu right at the pizza page
+	// an empty locale picks the user's language automatically
+	b.Handle("/start", flow.HandleStart("Hello there", ""))
+
+	// "@bot" in any chat shares the menu as an inline message
+	// chosen results must be enabled with @BotFather (/setinlinefeedback)
+	b.Handle(tb.OnQuery, func(q *tb.Query) {
+		result := &tb.ArticleResult{Title: "Menu", Text: "Hello there"}
+		result.SetReplyMarkup(flow.InlineKeyboard(&q.From, ""))
+		result.SetResultID("menu")
+		if err := b.Answer(q, &tb.QueryResponse{Results: tb.Results{result}, CacheTime: 0}); err != nil {
+			log.Println(err)
 		}
 	})
+	b.Handle(tb.OnChosenInlineResult, flow.HandleChosen("Hello there", ""))
+
+	// typed text goes to the menu: search queries, comments and other input
+	b.Handle(tb.OnText, func(m *tb.Message) {
+		flow.Process(m)
+	})
 
 	log.Println("starting...", b.Me.Username)
 
@@ -86,24 +127,24 @@ func userPressGreeting(e *menu.Node, c *tb.Callback) int {
 	return menu.Forward // continue
 }
 
-func userOrderSushi(e *menu.Node, c *tb.Callback) int {
-	log.Println(c.Sender.Recipient(), "press", e.GetText())
-	e.SetCaption(c, "Added "+e.GetText()+" to your order")
-	total += 5
-	return menu.Forward
+func userCheckout(e *menu.Node, c *tb.Callback, items []menu.CartItem) int {
+	total := 0
+	for _, item := range items {
+		total += prices[item.Node.GetText()] * item.Quantity
+	}
+	log.Println(c.Sender.Recipient(), "checkout", total)
+	e.SetCaption(c, "Your total is $"+strconv.Itoa(total))
+	return menu.Back
 }
 
-func userOrderPizza(e *menu.Node, c *tb.Callback) int {
-	log.Println(c.Sender.Recipient(), "press", e.GetText())
-	e.SetCaption(c, "Added "+e.GetText()+" to your order")
-	total += 10
-	return menu.Forward
+func userPickDate(e *menu.Node, c *tb.Callback, date time.Time) int {
+	log.Println(c.Sender.Recipient(), "picked", date.Format("2006-01-02"))
+	e.SetCaption(c, "Your table is booked for "+date.Format("January 2"))
+	return menu.Back
 }
 
-func userPressInvoice(e *menu.Node, c *tb.Callback) int {
-	log.Println(c.Sender.Recipient(), "press", e.GetText())
-	e.SetCaption(c, "Your total is $"+strconv.Itoa(total))
-	return menu.Forward
+func orderStatus(e *menu.Node, of tb.Recipient) string {
+	return "Your order is being cooked, it's " + time.Now().Format("15:04:05") + " now"
 }
 
 func userPressLanguage(e *menu.Node, c *tb.Callback) int {
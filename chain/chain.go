@@ -22,10 +22,14 @@ type Chain struct {
 	defaultLocale  string
 	positions      map[string]*Node
 	defaultHandler Callback
+	votes          map[string]*vote
 	mx             sync.RWMutex
 }
 
-var ErrChainIsEmpty = errors.New("chain has zero handlers")
+var (
+	ErrChainIsEmpty = errors.New("chain has zero handlers")
+	ErrNotRating    = errors.New("node is not a rating stage")
+)
 
 /*
 	Creates a new chain flow
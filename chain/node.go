@@ -19,6 +19,7 @@ type Node struct {
 	prev     *Node
 	next     *Node
 	event    string
+	rating   *Rating
 }
 
 /*
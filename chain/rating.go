@@ -0,0 +1,265 @@
+package chain
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"log"
+	"strconv"
+	"strings"
+)
+
+/*
+	Handler of a rating given by the user
+	The comment is empty when the user skipped it or it wasn't asked
+	Returns the next node like Callback, nil ends the chain
+*/
+type RatingHandler func(e *Node, of *tb.User, rating int, comment string) *Node
+
+/*
+	A stage that asks the user to rate with a row of stars
+	Stars is the highest rating, zero means 5
+	Comment asks the user for an optional comment after rating with the Prompt text
+	OK and Skip are labels of the buttons, when empty "OK" and "Skip" are used
+*/
+type Rating struct {
+	Stars   int
+	Comment bool
+	Prompt  string
+	OK      string
+	Skip    string
+	Handler RatingHandler
+}
+
+/*
+	A rating the user is giving at the moment
+	Its fields are guarded by the lock of the chain
+*/
+type vote struct {
+	rating     int
+	commenting bool
+	finished   bool
+	message    *tb.Message
+}
+
+/*
+	Steps of a rating that a press of its buttons leads to
+*/
+const (
+	voteIgnored = iota
+	voteRated
+	voteCommenting
+	voteFinished
+)
+
+/*
+	Creates a following rating stage in the list
+	The stars must be sent with AskRating, e.g. by the endpoint of the previous stage
+	Comments are passed to Chain.Process like any other text
+*/
+func (e *Node) ThenRating(id string, rating *Rating) *Node {
+	newNode := e.Then(id, nil, tb.OnText)
+	newNode.rating = rating
+	newNode.endpoint = newNode.handleComment
+	e.flow.handleRatings()
+	return newNode
+}
+
+/*
+	Sends the stars of a rating stage to the user and puts the user on the stage
+*/
+func (e *Node) AskRating(to tb.Recipient, text string) error {
+	if e.rating == nil {
+		return ErrNotRating
+	}
+	msg, err := e.flow.bot.Send(to, text, e.stars(0))
+	if err != nil {
+		return err
+	}
+	e.flow.setVote(to, &vote{message: msg})
+	e.flow.SetPosition(to, e)
+	return nil
+}
+
+/*
+	Registers a single handler for the stars of every rating stage of the chain
+	Only internal use is intended
+*/
+func (c *Chain) handleRatings() {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.votes != nil || c.bot == nil {
+		return
+	}
+	c.votes = make(map[string]*vote)
+	c.bot.Handle("\f"+c.unique(), c.handleRating)
+}
+
+/*
+	A unique callback endpoint of the rating stages
+*/
+func (c *Chain) unique() string {
+	return c.id + "_rating"
+}
+
+/*
+	Gets the rating the user is giving at the moment
+*/
+func (c *Chain) getVote(of tb.Recipient) (*vote, bool) {
+	c.mx.RLock()
+	v, ok := c.votes[of.Recipient()]
+	c.mx.RUnlock()
+	return v, ok
+}
+
+/*
+	Sets the rating the user is giving at the moment, nil forgets it
+*/
+func (c *Chain) setVote(of tb.Recipient, v *vote) {
+	c.mx.Lock()
+	if v == nil {
+		delete(c.votes, of.Recipient())
+	} else {
+		c.votes[of.Recipient()] = v
+	}
+	c.mx.Unlock()
+}
+
+/*
+	Renders a row of stars with the selected rating and the OK button
+*/
+func (e *Node) stars(rating int) *tb.ReplyMarkup {
+	count := e.rating.Stars
+	if count < 1 {
+		count = 5
+	}
+	stars := make([]tb.InlineButton, count)
+	for i := range stars {
+		star := "☆"
+		if i < rating {
+			star = "★"
+		}
+		stars[i] = e.ratingButton(star, "s"+strconv.Itoa(i+1))
+	}
+	keyboard := [][]tb.InlineButton{stars}
+	if rating > 0 {
+		keyboard = append(keyboard, []tb.InlineButton{e.ratingButton(label(e.rating.OK, "OK"), "ok")})
+	}
+	return &tb.ReplyMarkup{InlineKeyboard: keyboard}
+}
+
+/*
+	Creates a button of a rating stage
+*/
+func (e *Node) ratingButton(text, arg string) tb.InlineButton {
+	return tb.InlineButton{Unique: e.flow.unique(), Text: text, Data: e.id + "|" + arg}
+}
+
+/*
+	A default handler that aggregates the stars of every rating stage
+*/
+func (c *Chain) handleRating(cb *tb.Callback) {
+	defer c.bot.Respond(cb)
+	i := strings.LastIndex(cb.Data, "|")
+	if i < 0 {
+		return
+	}
+	node, ok := c.Search(cb.Data[:i])
+	v, voting := c.getVote(cb.Sender)
+	if position, _ := c.GetPosition(cb.Sender); !ok || !voting || position != node || node.rating == nil {
+		// the stars belong to a stage the user has already left
+		return
+	}
+	// presses of the same user may arrive at the same time
+	c.mx.Lock()
+	step := v.press(cb.Data[i+1:], node.rating.Comment)
+	rating := v.rating
+	c.mx.Unlock()
+	switch step {
+	case voteRated:
+		if _, err := c.bot.EditReplyMarkup(cb.Message, node.stars(rating)); err != nil {
+			log.Println("failed to update the rating", cb.Sender.ID, err)
+		}
+	case voteCommenting:
+		skip := &tb.ReplyMarkup{InlineKeyboard: [][]tb.InlineButton{
+			{node.ratingButton(label(node.rating.Skip, "Skip"), "skip")},
+		}}
+		if _, err := c.bot.Edit(cb.Message, label(node.rating.Prompt, "Any comments?"), skip); err != nil {
+			log.Println("failed to ask for a comment", cb.Sender.ID, err)
+		}
+	case voteFinished:
+		next := node.finish(cb.Sender, v, rating, "")
+		if next != node {
+			c.SetPosition(cb.Sender, next)
+		}
+	}
+}
+
+/*
+	Changes the vote by a pressed button and returns the step it leads to
+	The caller must hold the lock of the chain
+	Only internal use is intended
+*/
+func (v *vote) press(arg string, comment bool) int {
+	if v.finished {
+		return voteIgnored
+	}
+	switch {
+	case strings.HasPrefix(arg, "s") && !v.commenting:
+		rating, err := strconv.Atoi(arg[1:])
+		if err != nil || rating < 1 || v.rating == rating {
+			return voteIgnored
+		}
+		v.rating = rating
+		return voteRated
+	case arg == "ok" && v.rating > 0 && !v.commenting && comment:
+		v.commenting = true
+		return voteCommenting
+	case arg == "ok" && v.rating > 0 && !v.commenting, arg == "skip" && v.commenting:
+		v.finished = true
+		return voteFinished
+	}
+	return voteIgnored
+}
+
+/*
+	Endpoint of a rating stage that receives the comment
+	Text sent before the rating is picked keeps the user on the stage
+*/
+func (e *Node) handleComment(_ *Node, m *tb.Message) *Node {
+	v, ok := e.flow.getVote(m.Sender)
+	if !ok {
+		return e
+	}
+	e.flow.mx.Lock()
+	commented := v.commenting && !v.finished
+	v.finished = v.finished || commented
+	rating := v.rating
+	e.flow.mx.Unlock()
+	if !commented {
+		return e
+	}
+	return e.finish(m.Sender, v, rating, m.Text)
+}
+
+/*
+	Removes the buttons of the rating, passes the rating to the handler and forgets it
+*/
+func (e *Node) finish(of *tb.User, v *vote, rating int, comment string) *Node {
+	e.flow.setVote(of, nil)
+	if v.message != nil {
+		e.flow.bot.EditReplyMarkup(v.message, nil)
+	}
+	if e.rating.Handler == nil {
+		return e.next
+	}
+	return e.rating.Handler(e, of, rating, comment)
+}
+
+/*
+	Gets a label or a default one when it's empty
+*/
+func label(text, fallback string) string {
+	if text == "" {
+		return fallback
+	}
+	return text
+}
@@ -0,0 +1,34 @@
+package chain
+
+import (
+	"testing"
+)
+
+func TestVotePress(t *testing.T) {
+	tests := []struct {
+		name    string
+		vote    vote
+		arg     string
+		comment bool
+		step    int
+		rating  int
+	}{
+		{name: "first star", arg: "s3", step: voteRated, rating: 3},
+		{name: "same star", vote: vote{rating: 3}, arg: "s3", step: voteIgnored, rating: 3},
+		{name: "star out of range", arg: "s0", step: voteIgnored},
+		{name: "star that is not a number", arg: "sx", step: voteIgnored},
+		{name: "ok without a rating", arg: "ok", step: voteIgnored},
+		{name: "ok asks for a comment", vote: vote{rating: 2}, arg: "ok", comment: true, step: voteCommenting, rating: 2},
+		{name: "ok finishes", vote: vote{rating: 2}, arg: "ok", step: voteFinished, rating: 2},
+		{name: "stars while commenting", vote: vote{rating: 2, commenting: true}, arg: "s4", step: voteIgnored, rating: 2},
+		{name: "skip the comment", vote: vote{rating: 2, commenting: true}, arg: "skip", step: voteFinished, rating: 2},
+		{name: "skip without commenting", vote: vote{rating: 2}, arg: "skip", step: voteIgnored, rating: 2},
+		{name: "finished vote", vote: vote{rating: 2, finished: true}, arg: "s4", step: voteIgnored, rating: 2},
+	}
+	for _, tt := range tests {
+		v := tt.vote
+		if step := v.press(tt.arg, tt.comment); step != tt.step || v.rating != tt.rating {
+			t.Errorf("%s: step %d with rating %d; want %d with %d", tt.name, step, v.rating, tt.step, tt.rating)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package i18n
+
+/*
+	Translation helpers shared by menus and lists
+	Only internal use is intended
+*/
+
+import (
+	"github.com/tucnak/tr"
+	"sort"
+	"strings"
+)
+
+/*
+	A translation that is missing or empty in a specific language
+*/
+type MissingText struct {
+	Language string
+	Path     string
+	Empty    bool
+}
+
+/*
+	Error that lists every missing or empty translation of a menu or a list
+*/
+type ValidationError struct {
+	Missing []MissingText
+}
+
+/*
+	Lists every invalid translation on a separate line
+*/
+func (v *ValidationError) Error() string {
+	lines := make([]string, len(v.Missing))
+	for i, m := range v.Missing {
+		if m.Empty {
+			lines[i] = m.Language + ": empty " + m.Path
+		} else {
+			lines[i] = m.Language + ": missing " + m.Path
+		}
+	}
+	return "invalid translations:\n" + strings.Join(lines, "\n")
+}
+
+/*
+	Gets all languages of a locale engine in a stable order
+*/
+func Languages(engine *tr.Engine) []string {
+	langs := make([]string, 0, len(engine.Langs))
+	for lang := range engine.Langs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+/*
+	Gets the default locale of a locale engine
+	Falls back to the first language in a stable order when the engine has none
+*/
+func DefaultLocale(engine *tr.Engine) string {
+	if engine == nil {
+		return ""
+	}
+	if _, ok := engine.Langs[engine.DefaultLocale]; ok {
+		return engine.DefaultLocale
+	}
+	if langs := Languages(engine); len(langs) > 0 {
+		return langs[0]
+	}
+	return ""
+}
+
+/*
+	Appends a path to the list when it has no translation in a specified locale
+*/
+func Check(missing []MissingText, engine *tr.Engine, lang, path string) []MissingText {
+	text := engine.Lang(lang).Tr(path)
+	if text == path {
+		return append(missing, MissingText{Language: lang, Path: path})
+	}
+	if strings.TrimSpace(text) == "" {
+		return append(missing, MissingText{Language: lang, Path: path, Empty: true})
+	}
+	return missing
+}
+
+/*
+	Translates a locale path in a specified locale
+	Falls back to the default locale when enabled
+*/
+func Translate(engine *tr.Engine, lang, defaultLocale string, fallback bool, path string) string {
+	text := engine.Lang(lang).Tr(path)
+	if !fallback || defaultLocale == "" || lang == defaultLocale {
+		return text
+	}
+	if text == path || strings.TrimSpace(text) == "" {
+		return engine.Lang(defaultLocale).Tr(path)
+	}
+	return text
+}
+
+/*
+	Maps a Telegram language code (IETF tag) to one of available languages
+	Tries the exact code first and then the code without the region
+*/
+func MatchLanguage(available []string, code string) (string, bool) {
+	code = strings.ToLower(strings.Replace(code, "_", "-", -1))
+	if code == "" {
+		return "", false
+	}
+	base := code
+	if i := strings.Index(code, "-"); i > 0 {
+		base = code[:i]
+	}
+	for _, candidate := range []string{code, base} {
+		for _, lang := range available {
+			if strings.ToLower(strings.Replace(lang, "_", "-", -1)) == candidate {
+				return lang, true
+			}
+		}
+	}
+	return "", false
+}
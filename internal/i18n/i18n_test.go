@@ -0,0 +1,73 @@
+package i18n
+
+import (
+	"github.com/tucnak/tr"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newEngine(t *testing.T, texts map[string]string) *tr.Engine {
+	dir := t.TempDir()
+	for path, text := range texts {
+		file := filepath.Join(dir, filepath.FromSlash(path)+".txt")
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	engine, err := tr.NewEngine(dir, "en", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+func TestTranslate(t *testing.T) {
+	engine := newEngine(t, map[string]string{
+		"en/hello": "Hello",
+		"en/bye":   "Bye",
+		"ru/hello": "Привет",
+		"ru/bye":   " ",
+	})
+	tests := []struct {
+		lang     string
+		fallback bool
+		path     string
+		want     string
+	}{
+		{"ru", false, "hello", "Привет"},
+		{"ru", false, "missing", "missing"},
+		{"ru", true, "bye", "Bye"},
+		{"ru", true, "missing", "missing"},
+		{"en", true, "hello", "Hello"},
+	}
+	for _, tt := range tests {
+		if got := Translate(engine, tt.lang, "en", tt.fallback, tt.path); got != tt.want {
+			t.Errorf("Translate(%q, %v, %q) = %q; want %q", tt.lang, tt.fallback, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	engine := newEngine(t, map[string]string{"en/hello": "Hello", "en/empty": " "})
+	var missing []MissingText
+	for _, path := range []string{"hello", "empty", "missing"} {
+		missing = Check(missing, engine, "en", path)
+	}
+	want := []MissingText{{Language: "en", Path: "empty", Empty: true}, {Language: "en", Path: "missing"}}
+	if len(missing) != len(want) {
+		t.Fatalf("Check() = %v; want %v", missing, want)
+	}
+	for i := range want {
+		if missing[i] != want[i] {
+			t.Errorf("Check()[%d] = %v; want %v", i, missing[i], want[i])
+		}
+	}
+	err := &ValidationError{Missing: missing}
+	if got := err.Error(); got != "invalid translations:\nen: empty empty\nen: missing missing" {
+		t.Errorf("Error() = %q", got)
+	}
+}
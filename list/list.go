@@ -3,6 +3,7 @@ package list
 import (
 	"github.com/pkg/errors"
 	"github.com/tucnak/tr"
+	"go-telegram-flow/internal/i18n"
 	tb "gopkg.in/tucnak/telebot.v2"
 	"sync"
 )
@@ -23,34 +24,50 @@ var (
 	that is able to perform a callback when a user selects an answer from the list
 */
 type List struct {
-	id       string
-	engine   *tr.Engine
-	bot      *tb.Bot
-	markups  map[string]*tb.ReplyMarkup
-	links    map[string]map[string]int
-	sessions map[string]string
-	paths    []string
-	callback Callback
-	mx       sync.RWMutex
+	id            string
+	engine        *tr.Engine
+	bot           *tb.Bot
+	markups       map[string]*tb.ReplyMarkup
+	links         map[string]map[string]int
+	sessions      map[string]string
+	paths         []string
+	callback      Callback
+	defaultLocale string
+	fallback      bool
+	overrides     map[string]string
+	mx            sync.RWMutex
 }
 
+/*
+	A translation that is missing or empty in a specific language
+*/
+type MissingText = i18n.MissingText
+
+/*
+	Error that lists every missing or empty translation of a list
+*/
+type ValidationError = i18n.ValidationError
+
 /*
 	Creates a new list
+	The default locale of the engine becomes the default locale of the list
 */
 func NewListFlow(id string, textEngine *tr.Engine, bot *tb.Bot, callback Callback, textPaths ...string) (*List, error) {
 	if textPaths == nil || len(textPaths) < 1 {
 		return nil, ErrInvalidTextPath
 	}
 	return &List{
-		id:       id,
-		engine:   textEngine,
-		bot:      bot,
-		markups:  make(map[string]*tb.ReplyMarkup),
-		links:    make(map[string]map[string]int),
-		sessions: make(map[string]string), // user id -> language
-		paths:    textPaths,
-		callback: callback,
-		mx:       sync.RWMutex{},
+		id:            id,
+		engine:        textEngine,
+		defaultLocale: i18n.DefaultLocale(textEngine),
+		bot:           bot,
+		markups:       make(map[string]*tb.ReplyMarkup),
+		links:         make(map[string]map[string]int),
+		sessions:      make(map[string]string), // user id -> language
+		paths:         textPaths,
+		callback:      callback,
+		overrides:     make(map[string]string),
+		mx:            sync.RWMutex{},
 	}, nil
 }
 
@@ -61,7 +78,7 @@ func (l *List) Build(lang string) *List {
 	buttons := make([][]tb.ReplyButton, len(l.paths))
 	l.links[lang] = make(map[string]int)
 	for i, p := range l.paths {
-		text := l.engine.Lang(lang).Tr(p)
+		text := l.tr(lang, p)
 		btn := []tb.ReplyButton{
 			{
 				Text: text,
@@ -78,6 +95,69 @@ func (l *List) Build(lang string) *List {
 	return l
 }
 
+/*
+	Builds markups for every language of the locale engine
+*/
+func (l *List) BuildAll() *List {
+	for _, lang := range l.GetLanguages() {
+		l.Build(lang)
+	}
+	return l
+}
+
+/*
+	Gets all languages of the attached locale engine in a stable order
+*/
+func (l *List) GetLanguages() []string {
+	return i18n.Languages(l.engine)
+}
+
+/*
+	Sets a default locale of the list
+*/
+func (l *List) SetDefaultLocale(lang string) *List {
+	l.defaultLocale = lang
+	return l
+}
+
+/*
+	Uses translations of the default locale for missing or empty texts
+*/
+func (l *List) SetFallback(enabled bool) *List {
+	l.fallback = enabled
+	return l
+}
+
+/*
+	Checks that every item has a translation in the specified languages
+	or in all languages of the locale engine when none are specified
+	Returns a *ValidationError listing missing and empty translations
+*/
+func (l *List) Validate(langs ...string) error {
+	if len(langs) < 1 {
+		langs = l.GetLanguages()
+	}
+	var missing []MissingText
+	for _, lang := range langs {
+		for _, p := range l.paths {
+			missing = i18n.Check(missing, l.engine, lang, p)
+		}
+	}
+	if len(missing) > 0 {
+		return &ValidationError{Missing: missing}
+	}
+	return nil
+}
+
+/*
+	Translates a locale path in a specified locale
+	Falls back to the default locale when enabled
+	Only internal use is intended
+*/
+func (l *List) tr(lang, path string) string {
+	return i18n.Translate(l.engine, lang, l.defaultLocale, l.fallback, path)
+}
+
 /*
 	Gets a built markup in a specified language
 */
@@ -101,25 +181,84 @@ func (l *List) GetBot() *tb.Bot {
 
 /*
 	Starts a list flow for the user
+	An empty language is detected from the user's language
 */
 func (l *List) Start(to tb.Recipient, textPath, language string) error {
+	language = l.language(to, language)
 	if _, ok := l.engine.Langs[language]; !ok {
 		return ErrInvalidLanguage
 	}
 	l.setSession(to, language)
-	_, err := l.bot.Send(to, l.engine.Lang(language).Tr(textPath), l.GetMarkup(language))
+	_, err := l.bot.Send(to, l.tr(language, textPath), l.GetMarkup(language))
 	return err
 }
 
 /*
 	Starts a list flow for the user with a custom text
+	An empty language is detected from the user's language
 */
 func (l *List) StartWithText(to tb.Recipient, text, language string) error {
+	language = l.language(to, language)
 	l.setSession(to, language)
 	_, err := l.bot.Send(to, text, l.GetMarkup(language))
 	return err
 }
 
+/*
+	Remembers a language picked by the user
+	An empty language forgets the choice
+*/
+func (l *List) SetUserLanguage(of tb.Recipient, language string) *List {
+	l.mx.Lock()
+	if language == "" {
+		delete(l.overrides, of.Recipient())
+	} else {
+		l.overrides[of.Recipient()] = language
+	}
+	l.mx.Unlock()
+	return l
+}
+
+/*
+	Detects a language for the user
+	A language picked by the user goes first, then the Telegram language code
+	mapped to available locales (e.g. "en-US" -> "en") and the default locale at last
+*/
+func (l *List) DetectLanguage(user *tb.User) string {
+	if user == nil {
+		return l.defaultLocale
+	}
+	l.mx.RLock()
+	language, ok := l.overrides[user.Recipient()]
+	l.mx.RUnlock()
+	if ok {
+		return language
+	}
+	if language, ok := i18n.MatchLanguage(l.GetLanguages(), user.LanguageCode); ok {
+		return language
+	}
+	return l.defaultLocale
+}
+
+/*
+	Resolves a language of a recipient when none is specified
+	Only internal use is intended
+*/
+func (l *List) language(to tb.Recipient, language string) string {
+	if language != "" {
+		return language
+	}
+	if user, ok := to.(*tb.User); ok {
+		return l.DetectLanguage(user)
+	}
+	l.mx.RLock()
+	defer l.mx.RUnlock()
+	if language, ok := l.overrides[to.Recipient()]; ok {
+		return language
+	}
+	return l.defaultLocale
+}
+
 /*
 	Retrieves a session language by recipient
 */
@@ -0,0 +1,90 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+/*
+	Predicate that decides if a user is able to see or press a node
+*/
+type Predicate func(e *Node, of tb.Recipient) bool
+
+/*
+	Sets a predicate that hides the node from the users it returns false for
+	Hidden nodes are removed from the user's keyboard and their clicks are rejected
+	Returns the current node
+*/
+func (e *Node) SetVisible(visible Predicate) *Node {
+	e.visible = visible
+	return e
+}
+
+/*
+	Sets a guard that rejects clicks of the users it returns false for
+	The rejection is shown as an alert with a text from a locale path,
+	when the path is empty the menu default is used
+	Returns the current node
+*/
+func (e *Node) SetGuard(guard Predicate, deniedPath string) *Node {
+	e.guard = guard
+	e.deniedPath = deniedPath
+	return e
+}
+
+/*
+	Sets a locale path of the default alert shown on rejected clicks
+*/
+func (f *Menu) SetDeniedText(path string) *Menu {
+	f.deniedPath = path
+	return f
+}
+
+/*
+	Checks if the node is displayed to the user
+*/
+func (e *Node) IsVisible(of tb.Recipient) bool {
+	return e.visible == nil || e.visible(e, of)
+}
+
+/*
+	Checks if the user is allowed to press the node
+*/
+func (e *Node) IsAllowed(of tb.Recipient) bool {
+	return e.IsVisible(of) && (e.guard == nil || e.guard(e, of))
+}
+
+/*
+	Finds the first node on the way from the root to the node that the user may not press,
+	so the nodes under a hidden or guarded page are closed to the user as well
+	Returns nil when the whole way is open, the memo keeps the answers for nodes of the same user
+	Only internal use is intended
+*/
+func (e *Node) blocked(of tb.Recipient, memo map[*Node]*Node) *Node {
+	if b, known := memo[e]; known {
+		return b
+	}
+	var b *Node
+	if e.prev != nil {
+		b = e.prev.blocked(of, memo)
+	}
+	if b == nil && !e.IsAllowed(of) {
+		b = e
+	}
+	if memo != nil {
+		memo[e] = b
+	}
+	return b
+}
+
+/*
+	Rejects a click with a localized alert
+	Only internal use is intended
+*/
+func (e *Node) deny(c *tb.Callback) {
+	path := e.deniedPath
+	if path == "" || !e.IsVisible(c.Sender) {
+		path = e.flow.deniedPath
+	}
+	e.Alert(c, path)
+	e.flow.respond(c)
+}
@@ -0,0 +1,233 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	Handler of a date picked by the user in a calendar
+	Returns the same navigation results as Callback, e.g. Back or Stay
+*/
+type DateHandler func(e *Node, c *tb.Callback, date time.Time) int
+
+/*
+	A date picker that renders a month grid
+	Min and Max limit the dates that can be picked, zero dates leave the range open
+	Disabled rejects particular dates, e.g. weekends or booked days
+	Location is a time zone of the dates, when nil UTC is used
+*/
+type Calendar struct {
+	Min         time.Time
+	Max         time.Time
+	Disabled    func(date time.Time) bool
+	Location    *time.Location
+	SundayFirst bool
+	Handler     DateHandler
+}
+
+/*
+	The default locale path (relative to the menu id) of the directory
+	with month and weekday names, e.g. "calendar/january" and "calendar/monday"
+	Missing names are displayed in English
+*/
+const DefaultCalendarText = "calendar"
+
+const (
+	monthLayout = "2006-01"
+	dateLayout  = "2006-01-02"
+)
+
+/*
+	Sets a locale path of the directory with month and weekday names of calendars
+*/
+func (f *Menu) SetCalendarTexts(path string) *Menu {
+	f.calendarPath = path
+	return f
+}
+
+/*
+	Creates a new calendar node in the flow
+	that opens a month grid and passes the picked date to the handler of the calendar
+*/
+func (f *Menu) NewCalendarNode(text string, calendar *Calendar) *Node {
+	return newWidgetNode(f, text, calendar, f.root)
+}
+
+/*
+	Adds a new calendar node to the current node
+	Returns the current node
+*/
+func (e *Node) AddCalendar(text string, calendar *Calendar) *Node {
+	e.AddManySub([]*Node{newWidgetNode(e.flow, text, calendar, e)})
+	return e
+}
+
+/*
+	Checks if a date can be picked
+*/
+func (cal *Calendar) IsAllowed(date time.Time) bool {
+	day := cal.day(date)
+	if !cal.Min.IsZero() && day.Before(cal.day(cal.Min)) {
+		return false
+	}
+	if !cal.Max.IsZero() && day.After(cal.day(cal.Max)) {
+		return false
+	}
+	return cal.Disabled == nil || !cal.Disabled(day)
+}
+
+/*
+	Gets a time zone of the calendar
+*/
+func (cal *Calendar) location() *time.Location {
+	if cal.Location == nil {
+		return time.UTC
+	}
+	return cal.Location
+}
+
+/*
+	Truncates a time to the start of its day in the calendar time zone
+*/
+func (cal *Calendar) day(t time.Time) time.Time {
+	t = t.In(cal.location())
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, cal.location())
+}
+
+/*
+	Truncates a time to the start of its month in the calendar time zone
+*/
+func (cal *Calendar) month(t time.Time) time.Time {
+	t = t.In(cal.location())
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, cal.location())
+}
+
+/*
+	Gets a month displayed to the user
+	A month the user hasn't picked yet is the current one moved into the range
+*/
+func (cal *Calendar) shown(e *Node, of tb.Recipient) time.Time {
+	if value, ok := e.flow.getState(of.Recipient(), e.stateKey()); ok {
+		if month, err := time.ParseInLocation(monthLayout, value, cal.location()); err == nil {
+			return month
+		}
+	}
+	month := cal.month(time.Now())
+	if !cal.Min.IsZero() && month.Before(cal.month(cal.Min)) {
+		return cal.month(cal.Min)
+	}
+	if !cal.Max.IsZero() && month.After(cal.month(cal.Max)) {
+		return cal.month(cal.Max)
+	}
+	return month
+}
+
+/*
+	Renders the month grid for the user
+*/
+func (cal *Calendar) keyboard(e *Node, of tb.Recipient, lang string) [][]tb.InlineButton {
+	month := cal.shown(e, of)
+	prev, next := month.AddDate(0, -1, 0), month.AddDate(0, 1, 0)
+	header := []tb.InlineButton{
+		e.widgetButton(" ", noop),
+		e.widgetButton(e.flow.monthName(lang, month.Month())+" "+strconv.Itoa(month.Year()), noop),
+		e.widgetButton(" ", noop),
+	}
+	if cal.Min.IsZero() || !prev.Before(cal.month(cal.Min)) {
+		header[0] = e.widgetButton("«", "m"+prev.Format(monthLayout))
+	}
+	if cal.Max.IsZero() || !next.After(cal.month(cal.Max)) {
+		header[2] = e.widgetButton("»", "m"+next.Format(monthLayout))
+	}
+	first := time.Monday
+	if cal.SundayFirst {
+		first = time.Sunday
+	}
+	weekdays := make([]tb.InlineButton, 7)
+	for i := range weekdays {
+		weekdays[i] = e.widgetButton(e.flow.weekdayName(lang, (first+time.Weekday(i))%7), noop)
+	}
+	rows := [][]tb.InlineButton{header, weekdays}
+	// the grid starts on the first weekday before the first day of the month
+	day := month.AddDate(0, 0, -((int(month.Weekday()) - int(first) + 7) % 7))
+	for day.Before(next) {
+		week := make([]tb.InlineButton, 7)
+		for i := range week {
+			switch {
+			case day.Month() != month.Month():
+				week[i] = e.widgetButton(" ", noop)
+			case !cal.IsAllowed(day):
+				week[i] = e.widgetButton("·", noop)
+			default:
+				week[i] = e.widgetButton(strconv.Itoa(day.Day()), "d"+day.Format(dateLayout))
+			}
+			day = day.AddDate(0, 0, 1)
+		}
+		rows = append(rows, week)
+	}
+	return rows
+}
+
+/*
+	Switches months and passes picked dates to the handler
+*/
+func (cal *Calendar) press(e *Node, c *tb.Callback, arg string) {
+	if len(arg) < 1 {
+		e.flow.respond(c)
+		return
+	}
+	switch arg[0] {
+	case 'm':
+		month, err := time.ParseInLocation(monthLayout, arg[1:], cal.location())
+		e.flow.respond(c)
+		if err != nil {
+			return
+		}
+		e.flow.setState(c.Sender.Recipient(), e.stateKey(), month.Format(monthLayout))
+		e.refresh(c)
+	case 'd':
+		date, err := time.ParseInLocation(dateLayout, arg[1:], cal.location())
+		if err != nil || !cal.IsAllowed(date) {
+			// the rules might have changed since the grid was sent
+			e.flow.respond(c)
+			e.refresh(c)
+			return
+		}
+		result := Stay
+		if cal.Handler != nil {
+			e.flow.release(c, func() { result = cal.Handler(e, c, date) })
+		}
+		e.flow.respond(c)
+		e.navigate(c, result)
+	default:
+		e.flow.respond(c)
+	}
+}
+
+/*
+	Translates a month name, missing names are displayed in English
+*/
+func (f *Menu) monthName(lang string, month time.Month) string {
+	return f.trOr(lang, f.calendarPath+"/"+strings.ToLower(month.String()), month.String())
+}
+
+/*
+	Translates a short weekday name, missing names are displayed in English
+*/
+func (f *Menu) weekdayName(lang string, day time.Weekday) string {
+	return f.trOr(lang, f.calendarPath+"/"+strings.ToLower(day.String()), day.String()[:2])
+}
+
+/*
+	Translates a locale path and uses a default text when the translation is missing
+	Only internal use is intended
+*/
+func (f *Menu) trOr(lang, path, text string) string {
+	if translated := f.tr(lang, path); translated != path && strings.TrimSpace(translated) != "" {
+		return translated
+	}
+	return text
+}
@@ -0,0 +1,317 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"strconv"
+	"strings"
+)
+
+/*
+	An item in a cart of a user
+*/
+type CartItem struct {
+	Node     *Node
+	Quantity int
+}
+
+/*
+	Handler of a checkout that receives the items of the user's cart
+	The cart is cleared unless the handler returns Stay
+*/
+type CheckoutHandler func(e *Node, c *tb.Callback, items []CartItem) int
+
+/*
+	A shopping cart that keeps quantities of items per user in the user state
+	Items are nodes rendered with -/+ stepper buttons next to them
+*/
+type Cart struct {
+	id          string
+	flow        *Menu
+	checkout    CheckoutHandler
+	maxQuantity int
+}
+
+/*
+	The default locale path (relative to the menu id) of the directory
+	with texts of cart summaries, "cart/clear" and "cart/checkout"
+	Missing texts are displayed in English
+*/
+const DefaultCartText = "cart"
+
+const cartPrefix = "_cart_"
+
+/*
+	Creates a new cart in the flow
+	Every cart of the flow must have its own id
+*/
+func (f *Menu) NewCart(id string, checkout CheckoutHandler) *Cart {
+	return &Cart{id: id, flow: f, checkout: checkout}
+}
+
+/*
+	Sets the highest quantity of an item, zero allows any quantity
+*/
+func (cart *Cart) SetMaxQuantity(max int) *Cart {
+	cart.maxQuantity = max
+	return cart
+}
+
+/*
+	Sets a locale path of the directory with texts of cart summaries
+*/
+func (f *Menu) SetCartTexts(path string) *Menu {
+	f.cartPath = path
+	return f
+}
+
+/*
+	Creates a new item node of the cart
+	The item is rendered with -/+ buttons and its quantity, pressing the item adds one more
+*/
+func (cart *Cart) NewItemNode(text string) *Node {
+	return newItemNode(cart, text, cart.flow.root)
+}
+
+/*
+	Adds a new item node of a cart to the current node
+	Returns the current node
+*/
+func (e *Node) AddItem(cart *Cart, text string) *Node {
+	e.AddManySub([]*Node{newItemNode(cart, text, e)})
+	return e
+}
+
+/*
+	Creates a new cart summary node
+	that lists the items of the user with remove, clear and checkout buttons
+	With SetTypeToSearch text typed on the summary filters the items
+*/
+func (cart *Cart) NewSummaryNode(text string) *Node {
+	return newWidgetNode(cart.flow, text, &summary{cart: cart}, cart.flow.root)
+}
+
+/*
+	Adds a new cart summary node to the current node
+	Returns the current node
+*/
+func (e *Node) AddCartSummary(cart *Cart, text string) *Node {
+	e.AddManySub([]*Node{newWidgetNode(e.flow, text, &summary{cart: cart}, e)})
+	return e
+}
+
+/*
+	Creates a new item node
+	Only internal use is intended
+*/
+func newItemNode(cart *Cart, text string, prev *Node) *Node {
+	e := newWidgetNode(cart.flow, text, &stepper{cart: cart}, prev)
+	e.kind = kindItem
+	return e
+}
+
+/*
+	Gets the items of the user's cart in the order they were added
+*/
+func (cart *Cart) Items(of tb.Recipient) []CartItem {
+	value, _ := cart.flow.getState(of.Recipient(), cartPrefix+cart.id)
+	items := make([]CartItem, 0)
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) < 2 {
+			continue
+		}
+		quantity, err := strconv.Atoi(parts[1])
+		if err != nil || quantity < 1 {
+			continue
+		}
+		if node, ok := cart.flow.Search(parts[0]); ok {
+			items = append(items, CartItem{Node: node, Quantity: quantity})
+		}
+	}
+	return items
+}
+
+/*
+	Gets a quantity of an item in the user's cart
+*/
+func (cart *Cart) Quantity(of tb.Recipient, item *Node) int {
+	for _, it := range cart.Items(of) {
+		if it.Node == item {
+			return it.Quantity
+		}
+	}
+	return 0
+}
+
+/*
+	Sets a quantity of an item in the user's cart, zero removes the item
+*/
+func (cart *Cart) SetQuantity(of tb.Recipient, item *Node, quantity int) *Cart {
+	if cart.maxQuantity > 0 && quantity > cart.maxQuantity {
+		quantity = cart.maxQuantity
+	}
+	items := cart.Items(of)
+	found := false
+	for i := range items {
+		if items[i].Node == item {
+			items[i].Quantity = quantity
+			found = true
+		}
+	}
+	if !found {
+		items = append(items, CartItem{Node: item, Quantity: quantity})
+	}
+	pairs := make([]string, 0, len(items))
+	for _, it := range items {
+		if it.Quantity > 0 {
+			pairs = append(pairs, it.Node.id+"="+strconv.Itoa(it.Quantity))
+		}
+	}
+	cart.flow.setState(of.Recipient(), cartPrefix+cart.id, strings.Join(pairs, ","))
+	return cart
+}
+
+/*
+	Removes an item from the user's cart
+*/
+func (cart *Cart) Remove(of tb.Recipient, item *Node) *Cart {
+	return cart.SetQuantity(of, item, 0)
+}
+
+/*
+	Removes every item from the user's cart
+*/
+func (cart *Cart) Clear(of tb.Recipient) *Cart {
+	cart.flow.setState(of.Recipient(), cartPrefix+cart.id, "")
+	return cart
+}
+
+/*
+	Counts the items in the user's cart
+*/
+func (cart *Cart) Count(of tb.Recipient) int {
+	count := 0
+	for _, it := range cart.Items(of) {
+		count += it.Quantity
+	}
+	return count
+}
+
+/*
+	-/+ buttons rendered next to an item node
+*/
+type stepper struct {
+	cart *Cart
+}
+
+/*
+	Items have no page of their own
+*/
+func (s *stepper) keyboard(e *Node, of tb.Recipient, lang string) [][]tb.InlineButton {
+	return nil
+}
+
+/*
+	Renders the item with its quantity between -/+ buttons
+*/
+func (s *stepper) row(e *Node, of tb.Recipient, label string) []tb.InlineButton {
+	if quantity := s.cart.Quantity(of, e); quantity > 0 {
+		label += " ×" + strconv.Itoa(quantity)
+	}
+	// the item itself adds one more like the + button
+	item := tb.InlineButton{Unique: e.flow.unique(), Text: label, Data: e.id}
+	return []tb.InlineButton{e.widgetButton("−", "-"), item, e.widgetButton("+", "+")}
+}
+
+/*
+	Changes the quantity of the item and redraws the page
+*/
+func (s *stepper) press(e *Node, c *tb.Callback, arg string) {
+	quantity := s.cart.Quantity(c.Sender, e)
+	switch arg {
+	case "+":
+		quantity++
+	case "-":
+		quantity--
+	}
+	if quantity >= 0 {
+		s.cart.SetQuantity(c.Sender, e, quantity)
+	}
+	e.flow.respond(c)
+	e.redraw(c)
+}
+
+/*
+	A page that lists the items of a cart
+*/
+type summary struct {
+	cart *Cart
+}
+
+/*
+	Renders a remove button for every item and clear and checkout buttons
+	Items are filtered by the query the user has typed on the page
+*/
+func (s *summary) keyboard(e *Node, of tb.Recipient, lang string) [][]tb.InlineButton {
+	items := s.cart.Items(of)
+	query := e.query(of)
+	rows := make([][]tb.InlineButton, 0, len(items)+2)
+	for _, it := range items {
+		label := it.Node.personalize(of, lang, e.flow.tr(lang, it.Node.labelPath(it.Node.path)))
+		if !matchQuery(label, query) {
+			continue
+		}
+		rows = append(rows, []tb.InlineButton{
+			e.widgetButton("✕ "+label+" ×"+strconv.Itoa(it.Quantity), "r"+it.Node.id),
+		})
+	}
+	if len(items) > 0 {
+		rows = append(rows, []tb.InlineButton{
+			e.widgetButton(e.flow.trOr(lang, e.flow.cartPath+"/clear", "Clear"), "clr"),
+			e.widgetButton(e.flow.trOr(lang, e.flow.cartPath+"/checkout", "Checkout"), "ok"),
+		})
+	}
+	if row := e.queryRow(of, lang); row != nil {
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+/*
+	Filters the items by a typed query
+*/
+func (s *summary) search(e *Node, d *Dialog, m *tb.Message) bool {
+	return e.filter(d, m)
+}
+
+/*
+	Removes items, clears the cart and passes the items to the checkout handler
+*/
+func (s *summary) press(e *Node, c *tb.Callback, arg string) {
+	switch {
+	case strings.HasPrefix(arg, "r"):
+		if item, ok := e.flow.Search(arg[1:]); ok {
+			s.cart.Remove(c.Sender, item)
+		}
+	case arg == "clr":
+		s.cart.Clear(c.Sender)
+	case arg == "ok":
+		items := s.cart.Items(c.Sender)
+		if len(items) < 1 {
+			break
+		}
+		result := Stay
+		if s.cart.checkout != nil {
+			e.flow.release(c, func() { result = s.cart.checkout(e, c, items) })
+		}
+		e.flow.respond(c)
+		if result != Stay {
+			s.cart.Clear(c.Sender)
+			e.mustUpdate = true
+		}
+		e.navigate(c, result)
+		return
+	}
+	e.flow.respond(c)
+	e.refresh(c)
+}
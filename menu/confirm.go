@@ -0,0 +1,141 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"log"
+)
+
+/*
+	The default locale paths (relative to the menu id) of confirmation buttons
+*/
+const (
+	DefaultConfirmText = "confirm"
+	DefaultCancelText  = "cancel"
+)
+
+/*
+	Sets locale paths of the confirm and cancel buttons of confirmation nodes
+*/
+func (f *Menu) SetConfirmTexts(confirmPath, cancelPath string) *Menu {
+	f.confirmPath = confirmPath
+	f.cancelPath = cancelPath
+	return f
+}
+
+/*
+	Creates a new confirmation node in the flow
+	that asks the user to confirm the action before the endpoint runs
+	The question is a locale path that replaces the caption while asking,
+	when empty the caption stays the same
+*/
+func (f *Menu) NewConfirmNode(text string, endpoint Callback, questionPath string) *Node {
+	return newConfirmNode(f, text, endpoint, questionPath, f.root)
+}
+
+/*
+	Adds a new confirmation node to the current node
+	Returns the current node
+*/
+func (e *Node) AddConfirm(text string, endpoint Callback, questionPath string) *Node {
+	e.AddManySub([]*Node{newConfirmNode(e.flow, text, endpoint, questionPath, e)})
+	return e
+}
+
+/*
+	Creates a new confirmation node with confirm and cancel sub nodes
+	Only internal use is intended
+*/
+func newConfirmNode(root *Menu, text string, endpoint Callback, questionPath string, prev *Node) *Node {
+	e := newNode(root, text, endpoint, prev)
+	e.kind = kindConfirm
+	e.question = questionPath
+	yes := newNode(root, DefaultConfirmText, nil, e)
+	yes.kind = kindConfirmYes
+	no := newNode(root, DefaultCancelText, nil, e)
+	no.kind = kindConfirmNo
+	e.nodes = []*Node{yes, no}
+	return e
+}
+
+/*
+	Gets a locale path of the node label
+	Confirmation buttons share the texts of the menu
+*/
+func (e *Node) labelPath(path string) string {
+	switch e.kind {
+	case kindConfirmYes:
+		return e.flow.confirmPath
+	case kindConfirmNo:
+		return e.flow.cancelPath
+	}
+	return path
+}
+
+/*
+	Handler for confirmation nodes that asks the user to confirm the action
+*/
+func (e *Node) handleConfirm(c *tb.Callback) {
+	e.flow.respond(c)
+	d, ok := e.flow.dialogOf(c)
+	if !ok {
+		log.Println(c.Sender.ID, "does not exist")
+		return
+	}
+	d.caption = d.Message.Text
+	d.question = ""
+	if e.question != "" {
+		d.question = e.Translate(c, e.question)
+		d.Message.Text = d.question
+	}
+	from := d.Position
+	if e.update(c.Sender, d, e) {
+		e.flow.remember(d, from, e)
+	} else {
+		d.Message.Text = d.caption
+	}
+}
+
+/*
+	Runs the endpoint of a confirmation node and returns to the previous page
+*/
+func (e *Node) accept(c *tb.Callback) {
+	result := Stay
+	if e.endpoint != nil {
+		e.flow.release(c, func() { result = e.endpoint(e, c) })
+	}
+	e.flow.respond(c)
+	e.restore(c)
+	if result == Home {
+		e.home(c)
+		return
+	}
+	e.back(c)
+}
+
+/*
+	Cancels the action of a confirmation or input node and returns to the previous page
+*/
+func (e *Node) cancel(c *tb.Callback) {
+	e.flow.respond(c)
+	if d, ok := e.flow.dialogOf(c); ok {
+		d.input = nil
+	}
+	e.restore(c)
+	e.back(c)
+}
+
+/*
+	Restores the caption that was displayed before the confirmation
+	unless the endpoint has set a new one
+*/
+func (e *Node) restore(c *tb.Callback) {
+	d, ok := e.flow.dialogOf(c)
+	if !ok {
+		return
+	}
+	if d.question != "" && d.Message.Text == d.question {
+		d.Message.Text = d.caption
+	}
+	d.caption = ""
+	d.question = ""
+}
@@ -0,0 +1,90 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"log"
+	"strings"
+)
+
+/*
+	Separates parts of a deep link payload
+	Telegram only allows letters, digits, underscores and hyphens in a payload
+*/
+const payloadSeparator = "-"
+
+/*
+	Creates a /start deep link payload that opens the menu at a node
+	The payload addresses the node by its ID
+*/
+func (f *Menu) DeepLink(node *Node) string {
+	return f.id + payloadSeparator + node.id
+}
+
+/*
+	Creates a t.me link that opens the menu at a node
+*/
+func (f *Menu) DeepLinkURL(node *Node) string {
+	return "https://t.me/" + f.bot.Me.Username + "?start=" + f.DeepLink(node)
+}
+
+/*
+	Resolves a node from a deep link payload
+	Accepts "<menu id>-<node id>" or a path like "<menu id>-order-pizza"
+*/
+func (f *Menu) Resolve(payload string) (*Node, bool) {
+	if payload == f.id {
+		return f.root, true
+	}
+	if !strings.HasPrefix(payload, f.id+payloadSeparator) {
+		return nil, false
+	}
+	address := strings.TrimPrefix(payload, f.id+payloadSeparator)
+	if node, ok := f.Search(address); ok {
+		return node, true
+	}
+	return f.Find(strings.Replace(address, payloadSeparator, "/", -1))
+}
+
+/*
+	Sends an instance of a menu to a user starting at a node addressed by a deep link payload
+	An empty payload starts the menu at the root
+*/
+func (f *Menu) StartFromPayload(to tb.Recipient, text, lang, payload string) error {
+	return f.startFromPayload(to, to, text, lang, payload)
+}
+
+/*
+	Sends an instance of a menu to a chat on behalf of a user starting at a node addressed by a payload
+	Only internal use is intended
+*/
+func (f *Menu) startFromPayload(to, owner tb.Recipient, text, lang, payload string) error {
+	if payload == "" {
+		return f.StartFor(to, owner, text, lang)
+	}
+	at, ok := f.Resolve(payload)
+	if !ok {
+		return ErrNodeNotFound
+	}
+	return f.StartAtFor(to, owner, text, lang, at)
+}
+
+/*
+	Creates a /start handler that opens the menu at a node from the deep link payload
+	The menu is posted in the chat of the command and belongs to the user who sent it
+	Unknown payloads open the menu at the root
+*/
+func (f *Menu) HandleStart(text, lang string) func(m *tb.Message) {
+	return func(m *tb.Message) {
+		var to tb.Recipient = m.Sender
+		if m.Chat != nil {
+			to = m.Chat
+		}
+		err := f.startFromPayload(to, m.Sender, text, lang, m.Payload)
+		if err == ErrNodeNotFound {
+			err = f.StartFor(to, m.Sender, text, lang)
+		}
+		if err != nil {
+			log.Println("failed to start", m.Sender.Recipient(), err)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"strconv"
+	"time"
+)
+
+/*
+	Sets the amount of menus a user can have open at the same time
+	Zero or a negative limit allows any amount
+*/
+func (f *Menu) SetDialogLimit(limit int) *Menu {
+	f.dialogLimit = limit
+	return f
+}
+
+/*
+	Retrieves the latest dialog by user id
+*/
+func (f *Menu) GetDialog(id string) (*Dialog, bool) {
+	f.mx.RLock()
+	d, ok := f.latest(id)
+	f.mx.RUnlock()
+	return d, ok
+}
+
+/*
+	Retrieves all dialogs by user id from the oldest to the latest
+*/
+func (f *Menu) GetDialogs(id string) []*Dialog {
+	f.mx.RLock()
+	dialogs := append([]*Dialog(nil), f.users[id]...)
+	f.mx.RUnlock()
+	return dialogs
+}
+
+/*
+	Retrieves a dialog by its menu message
+*/
+func (f *Menu) GetDialogByMessage(msg *tb.Message) (*Dialog, bool) {
+	f.mx.RLock()
+	d, ok := f.dialogs[dialogKey(msg)]
+	f.mx.RUnlock()
+	return d, ok
+}
+
+/*
+	Retrieves a dialog of the message a button was pressed on
+	Falls back to the dialog the user is interacting with unless the message is an inline one
+	Only internal use is intended
+*/
+func (f *Menu) dialogOf(c *tb.Callback) (*Dialog, bool) {
+	if c.Message != nil {
+		if d, ok := f.GetDialogByMessage(c.Message); ok || isInline(c.Message) {
+			return d, ok
+		}
+	}
+	return f.GetActiveDialog(c.Sender.Recipient())
+}
+
+/*
+	Gets the dialog a user has pressed a button of or typed a reply to last,
+	falls back to the latest dialog of the user
+*/
+func (f *Menu) GetActiveDialog(id string) (*Dialog, bool) {
+	f.mx.RLock()
+	d, ok := f.current(id)
+	f.mx.RUnlock()
+	return d, ok
+}
+
+/*
+	Makes a dialog the one the user is interacting with
+	State of toggles and widgets is read from and written to that dialog
+	Only internal use is intended
+*/
+func (f *Menu) activate(of tb.Recipient, dialog *Dialog) {
+	f.mx.Lock()
+	if f.dialogs[dialog.key] == dialog {
+		f.active[of.Recipient()] = dialog
+	}
+	f.mx.Unlock()
+}
+
+/*
+	Locks a dialog while a callback or a text of a user is processed in it
+	Returns a function that unlocks the dialog
+	Only internal use is intended
+*/
+func (f *Menu) hold(c *tb.Callback, d *Dialog) func() {
+	d.mx.Lock()
+	f.mx.Lock()
+	f.held[c] = d
+	f.mx.Unlock()
+	return func() {
+		f.mx.Lock()
+		delete(f.held, c)
+		f.mx.Unlock()
+		d.mx.Unlock()
+	}
+}
+
+/*
+	Runs code of the user, e.g. an endpoint, without the lock of the dialog the callback is processed in,
+	so the code may change the menu with its public methods
+	Only internal use is intended
+*/
+func (f *Menu) release(c *tb.Callback, run func()) {
+	f.mx.RLock()
+	d, ok := f.held[c]
+	f.mx.RUnlock()
+	if !ok {
+		run()
+		return
+	}
+	d.mx.Unlock()
+	defer d.mx.Lock()
+	run()
+}
+
+/*
+	Gets the dialog a user is interacting with, the caller must hold the lock
+	Only internal use is intended
+*/
+func (f *Menu) current(id string) (*Dialog, bool) {
+	if d, ok := f.active[id]; ok && f.dialogs[d.key] == d {
+		return d, true
+	}
+	return f.latest(id)
+}
+
+/*
+	Gets the latest dialog of a user, the caller must hold the lock
+	Only internal use is intended
+*/
+func (f *Menu) latest(id string) (*Dialog, bool) {
+	dialogs := f.users[id]
+	if len(dialogs) < 1 {
+		return nil, false
+	}
+	return dialogs[len(dialogs)-1], true
+}
+
+/*
+	Sets a dialog by a user id and indexes it by its message
+	The dialog becomes the latest one of the user
+	Inline dialogs over the limit are forgotten
+	Only internal use is intended
+*/
+func (f *Menu) setDialog(id string, dialog *Dialog) {
+	f.mx.Lock()
+	f.unlink(dialog)
+	dialog.user = id
+	dialog.key = dialogKey(dialog.Message)
+	f.dialogs[dialog.key] = dialog
+	f.users[id] = append(f.users[id], dialog)
+	f.active[id] = dialog
+	if dialog.owner != "" {
+		f.active[dialog.owner] = dialog
+	}
+	dialog.touched = time.Now()
+	if isInline(dialog.Message) {
+		f.trimInline()
+	}
+	f.mx.Unlock()
+}
+
+/*
+	Indexes a dialog again after its message has been replaced
+	Only internal use is intended
+*/
+func (f *Menu) reindex(dialog *Dialog) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	if dialog.key == dialogKey(dialog.Message) || f.dialogs[dialog.key] != dialog {
+		return
+	}
+	delete(f.dialogs, dialog.key)
+	dialog.key = dialogKey(dialog.Message)
+	f.dialogs[dialog.key] = dialog
+}
+
+/*
+	Deletes a single dialog
+	Only internal use is intended
+*/
+func (f *Menu) removeDialog(dialog *Dialog) {
+	f.mx.Lock()
+	f.unlink(dialog)
+	f.mx.Unlock()
+}
+
+/*
+	Removes a dialog from the indexes, the caller must hold the lock
+	Only internal use is intended
+*/
+func (f *Menu) unlink(dialog *Dialog) {
+	if f.dialogs[dialog.key] == dialog {
+		delete(f.dialogs, dialog.key)
+	}
+	for _, id := range []string{dialog.user, dialog.owner} {
+		if f.active[id] == dialog {
+			delete(f.active, id)
+		}
+	}
+	dialogs := f.users[dialog.user]
+	for i, d := range dialogs {
+		if d == dialog {
+			dialogs = append(dialogs[:i:i], dialogs[i+1:]...)
+			break
+		}
+	}
+	if len(dialogs) > 0 {
+		f.users[dialog.user] = dialogs
+	} else {
+		delete(f.users, dialog.user)
+	}
+}
+
+/*
+	Makes room for a new menu of a user by deleting the oldest ones
+	Returns the latest deleted dialog if any
+	Only internal use is intended
+*/
+func (f *Menu) evict(to tb.Recipient) *Dialog {
+	if f.dialogLimit < 1 {
+		return nil
+	}
+	var evicted *Dialog
+	dialogs := make([]*Dialog, 0)
+	for _, d := range f.GetDialogs(to.Recipient()) {
+		// inline messages are shared into other chats and cannot be deleted
+		if !isInline(d.Message) {
+			dialogs = append(dialogs, d)
+		}
+	}
+	for len(dialogs) >= f.dialogLimit {
+		evicted, dialogs = dialogs[0], dialogs[1:]
+		evicted.mx.Lock()
+		f.bot.Delete(evicted.Message)
+		f.removeDialog(evicted)
+		evicted.mx.Unlock()
+	}
+	return evicted
+}
+
+/*
+	Checks if a callback comes from a menu message that is not open anymore
+	Only internal use is intended
+*/
+func (f *Menu) isStale(c *tb.Callback) bool {
+	if c.Message == nil || c.Message.InlineID != "" {
+		return false
+	}
+	if _, ok := f.GetDialogByMessage(c.Message); ok {
+		return false
+	}
+	// menus are kept per chat, e.g. a group the menu was posted in
+	chat := c.Sender.Recipient()
+	if c.Message.Chat != nil {
+		chat = c.Message.Chat.Recipient()
+	}
+	_, ok := f.GetDialog(chat)
+	return ok
+}
+
+/*
+	Creates a key of a dialog from its chat and message ID
+	or from the inline message ID
+*/
+func dialogKey(msg *tb.Message) string {
+	if msg == nil {
+		return ""
+	}
+	if msg.InlineID != "" {
+		return "inline:" + msg.InlineID
+	}
+	var chat int64
+	if msg.Chat != nil {
+		chat = msg.Chat.ID
+	}
+	return strconv.FormatInt(chat, 10) + ":" + strconv.Itoa(msg.ID)
+}
+
+/*
+	Pushes a page the user leaves to the dialog history
+	Only internal use is intended
+*/
+func (f *Menu) remember(d *Dialog, from, to *Node) {
+	if from == nil || from == to {
+		return
+	}
+	if f.historyLimit < 1 {
+		d.History = nil
+		return
+	}
+	d.History = append(d.History, from)
+	if len(d.History) > f.historyLimit {
+		d.History = d.History[len(d.History)-f.historyLimit:]
+	}
+}
+
+/*
+	Returns the last visited page without removing it from the history
+*/
+func (d *Dialog) Previous() *Node {
+	if len(d.History) < 1 {
+		return nil
+	}
+	return d.History[len(d.History)-1]
+}
@@ -0,0 +1,197 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"testing"
+)
+
+func TestDialogKey(t *testing.T) {
+	tests := []struct {
+		msg  *tb.Message
+		want string
+	}{
+		{nil, ""},
+		{&tb.Message{ID: 7, Chat: &tb.Chat{ID: 42}}, "42:7"},
+		{&tb.Message{ID: 7, Chat: &tb.Chat{ID: -100}}, "-100:7"},
+		{&tb.Message{ID: 7}, "0:7"},
+		{&tb.Message{InlineID: "AAE"}, "inline:AAE"},
+	}
+	for _, tt := range tests {
+		if got := dialogKey(tt.msg); got != tt.want {
+			t.Errorf("dialogKey(%v) = %q; want %q", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestDialogLimit(t *testing.T) {
+	user := &tb.User{ID: 1}
+	tests := []struct {
+		limit   int
+		starts  int
+		open    int
+		deleted int
+	}{
+		{limit: 1, starts: 1, open: 1, deleted: 0},
+		{limit: 1, starts: 3, open: 1, deleted: 2},
+		{limit: 2, starts: 3, open: 2, deleted: 1},
+		{limit: 0, starts: 3, open: 3, deleted: 0},
+	}
+	for _, tt := range tests {
+		f, api := newTestMenu(t, nil)
+		f.SetDialogLimit(tt.limit).Build("en")
+		messages := make([]*tb.Message, 0, tt.starts)
+		for i := 0; i < tt.starts; i++ {
+			messages = append(messages, startDialog(t, f, user).Message)
+		}
+		dialogs := f.GetDialogs(user.Recipient())
+		if len(dialogs) != tt.open {
+			t.Errorf("limit %d, %d starts: %d open; want %d", tt.limit, tt.starts, len(dialogs), tt.open)
+		}
+		deleted := 0
+		for _, method := range api.methods() {
+			if method == "deleteMessage" {
+				deleted++
+			}
+		}
+		if deleted != tt.deleted {
+			t.Errorf("limit %d, %d starts: %d deleted; want %d", tt.limit, tt.starts, deleted, tt.deleted)
+		}
+		for i, msg := range messages {
+			_, ok := f.GetDialogByMessage(msg)
+			if want := i >= tt.starts-tt.open; ok != want {
+				t.Errorf("limit %d, %d starts: message %d is open %v; want %v", tt.limit, tt.starts, i, ok, want)
+			}
+		}
+		if latest, _ := f.GetDialog(user.Recipient()); latest.Message != messages[len(messages)-1] {
+			t.Errorf("limit %d, %d starts: the latest dialog is not the last started one", tt.limit, tt.starts)
+		}
+	}
+}
+
+func TestRemember(t *testing.T) {
+	f, _ := newTestMenu(t, nil)
+	a, b, c := f.NewNode("a", nil), f.NewNode("b", nil), f.NewNode("c", nil)
+	tests := []struct {
+		limit  int
+		visits []*Node
+		want   []*Node
+	}{
+		{limit: 16, visits: []*Node{a, b, c}, want: []*Node{a, b}},
+		{limit: 2, visits: []*Node{a, b, c, a}, want: []*Node{b, c}},
+		{limit: 16, visits: []*Node{a, a, b}, want: []*Node{a}},
+		{limit: 0, visits: []*Node{a, b, c}, want: nil},
+	}
+	for _, tt := range tests {
+		f.SetHistoryLimit(tt.limit)
+		d := &Dialog{}
+		var from *Node
+		for _, to := range tt.visits {
+			f.remember(d, from, to)
+			from = to
+		}
+		if len(d.History) != len(tt.want) {
+			t.Errorf("limit %d: history of %d pages; want %d", tt.limit, len(d.History), len(tt.want))
+			continue
+		}
+		for i := range tt.want {
+			if d.History[i] != tt.want[i] {
+				t.Errorf("limit %d: page %d is %q; want %q", tt.limit, i, d.History[i].text, tt.want[i].text)
+			}
+		}
+	}
+}
+
+func TestIsStale(t *testing.T) {
+	user := &tb.User{ID: 1}
+	stranger := &tb.User{ID: 2}
+	f, _ := newTestMenu(t, nil)
+	f.Build("en")
+	old := startDialog(t, f, user).Message
+	current := startDialog(t, f, user).Message
+	tests := []struct {
+		name  string
+		from  *tb.User
+		msg   *tb.Message
+		stale bool
+	}{
+		{"current menu", user, current, false},
+		{"replaced menu", user, old, true},
+		{"inline message", user, &tb.Message{InlineID: "AAE"}, false},
+		{"no message", user, nil, false},
+		{"replaced menu pressed by another user", stranger, old, true},
+		{"chat without menus", stranger, &tb.Message{ID: 1, Chat: &tb.Chat{ID: 2}}, false},
+	}
+	for _, tt := range tests {
+		if got := f.isStale(newCallback(tt.from, tt.msg, "1")); got != tt.stale {
+			t.Errorf("%s: stale %v; want %v", tt.name, got, tt.stale)
+		}
+	}
+}
+
+func TestStatePerDialog(t *testing.T) {
+	user := &tb.User{ID: 1}
+	f, _ := newTestMenu(t, map[string]string{"flow/t": "Toggle"})
+	f.SetDialogLimit(3).GetRoot().AddToggle("t", nil).GetFlow().Build("en")
+	toggle, _ := f.Find("t")
+	older := startDialog(t, f, user)
+	newer := startDialog(t, f, user)
+
+	// a toggle pressed in the older menu changes only that menu
+	f.handleCallback(newCallback(user, older.Message, toggle.id))
+	if older.State[toggle.id] != stateOn {
+		t.Errorf("older menu state %q; want %q", older.State[toggle.id], stateOn)
+	}
+	if _, ok := newer.State[toggle.id]; ok {
+		t.Errorf("newer menu state %q; want none", newer.State[toggle.id])
+	}
+	if active, _ := f.GetActiveDialog(user.Recipient()); active != older {
+		t.Error("the pressed menu is not the active one")
+	}
+
+	// a new menu starts with a copy of the state of the active menu
+	latest := startDialog(t, f, user)
+	if latest.State[toggle.id] != stateOn {
+		t.Errorf("new menu state %q; want %q", latest.State[toggle.id], stateOn)
+	}
+	f.handleCallback(newCallback(user, latest.Message, toggle.id))
+	if latest.State[toggle.id] != stateOff || older.State[toggle.id] != stateOn {
+		t.Errorf("states %q and %q; want %q and %q", latest.State[toggle.id], older.State[toggle.id], stateOff, stateOn)
+	}
+}
+
+func TestActiveDialogChanges(t *testing.T) {
+	user := &tb.User{ID: 1}
+	f, _ := newTestMenu(t, map[string]string{"flow/move": "Move", "flow/caption": "Caption", "flow/target": "Target"})
+	var target *Node
+	f.SetDialogLimit(2).GetRoot().
+		Add("move", func(e *Node, c *tb.Callback) int {
+			if err := f.MoveTo(c.Sender, "Moved", "", target); err != nil {
+				t.Error(err)
+			}
+			return Stay
+		}).
+		Add("caption", func(e *Node, c *tb.Callback) int {
+			f.SetCaption(c.Sender, "Caption %d", 1)
+			return Stay
+		}).
+		Add("target", nil).
+		GetFlow().Build("en")
+	target, _ = f.Find("target")
+	move, _ := f.Find("move")
+	caption, _ := f.Find("caption")
+	first := startDialog(t, f, user)
+	second := startDialog(t, f, user)
+
+	// the menu a button was pressed in changes, not the latest one
+	f.handleCallback(newCallback(user, first.Message, caption.id))
+	if first.Message.Text != "Caption 1" || second.Message.Text == "Caption 1" {
+		t.Errorf("captions %q and %q; want only the first one set", first.Message.Text, second.Message.Text)
+	}
+	f.handleCallback(newCallback(user, first.Message, move.id))
+	if first.Position != target {
+		t.Errorf("the pressed menu is at %q; want %q", first.Position.path, target.path)
+	}
+	if second.Position != f.root {
+		t.Errorf("the other menu is at %q; want the root", second.Position.path)
+	}
+}
@@ -0,0 +1,57 @@
+package menu
+
+import (
+	"errors"
+	"strings"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+/*
+	Checks if Telegram refused an edit because nothing has changed
+	Such an edit is treated as a success
+*/
+func IsNotModified(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, tb.ErrMessageNotModified) {
+		return true
+	}
+	// newer descriptions carry details, so telebot does not map them to the sentinel
+	return strings.Contains(strings.ToLower(err.Error()), "message is not modified")
+}
+
+/*
+	Checks if Telegram refused an edit because the message is gone
+	e.g. the user has deleted it or it is too old to be edited
+*/
+func IsMessageGone(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, tb.ErrCantEditMessage) {
+		return true
+	}
+	// telebot has no sentinels for these descriptions
+	text := strings.ToLower(err.Error())
+	for _, reason := range []string{
+		"message to edit not found",
+		"message_id_invalid",
+		"message not found",
+	} {
+		if strings.Contains(text, reason) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	Checks if an edit has succeeded
+	Edits of inline messages report their success with tb.ErrTrueResult
+	Only internal use is intended
+*/
+func isEdited(err error) bool {
+	return err == nil || errors.Is(err, tb.ErrTrueResult) || IsNotModified(err)
+}
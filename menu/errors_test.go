@@ -0,0 +1,44 @@
+package menu
+
+import (
+	"errors"
+	"fmt"
+	tb "gopkg.in/tucnak/telebot.v2"
+	"testing"
+)
+
+func TestEditErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		notModified bool
+		gone        bool
+		edited      bool
+	}{
+		{name: "success", err: nil, edited: true},
+		{name: "inline success", err: tb.ErrTrueResult, edited: true},
+		{name: "not modified sentinel", err: tb.ErrMessageNotModified, notModified: true, edited: true},
+		{name: "wrapped sentinel", err: fmt.Errorf("refresh: %w", tb.ErrMessageNotModified), notModified: true, edited: true},
+		{
+			name:        "not modified with details",
+			err:         tb.NewAPIError(400, "Bad Request: message is not modified: specified new message content and reply markup are exactly the same"),
+			notModified: true,
+			edited:      true,
+		},
+		{name: "can't edit sentinel", err: tb.ErrCantEditMessage, gone: true},
+		{name: "message to edit not found", err: tb.NewAPIError(400, "Bad Request: message to edit not found"), gone: true},
+		{name: "invalid id", err: tb.NewAPIError(400, "Bad Request: MESSAGE_ID_INVALID"), gone: true},
+		{name: "other error", err: errors.New("telegram: chat not found (400)")},
+	}
+	for _, tt := range tests {
+		if got := IsNotModified(tt.err); got != tt.notModified {
+			t.Errorf("%s: IsNotModified %v; want %v", tt.name, got, tt.notModified)
+		}
+		if got := IsMessageGone(tt.err); got != tt.gone {
+			t.Errorf("%s: IsMessageGone %v; want %v", tt.name, got, tt.gone)
+		}
+		if got := isEdited(tt.err); got != tt.edited {
+			t.Errorf("%s: isEdited %v; want %v", tt.name, got, tt.edited)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package menu
+
+import (
+	"github.com/pkg/errors"
+	tb "gopkg.in/tucnak/telebot.v2"
+	"log"
+	"sort"
+)
+
+var ErrInlineReplyKeyboard = errors.New("inline messages cannot carry reply keyboards")
+
+/*
+	The default amount of inline messages the menu keeps track of
+*/
+const DefaultInlineLimit = 1000
+
+/*
+	Sets the amount of inline messages the menu keeps track of
+	The least recently used ones are forgotten, their buttons start working again once pressed
+	Zero or a negative limit keeps track of every inline message
+*/
+func (f *Menu) SetInlineLimit(limit int) *Menu {
+	f.inlineLimit = limit
+	return f
+}
+
+/*
+	Lets users type into menus attached to inline messages, e.g. replies to input nodes
+	Such a menu is in a chat of its own, so texts of its users are ignored unless enabled,
+	then texts from any chat with the bot reach it
+*/
+func (f *Menu) SetInlineInput(enabled bool) *Menu {
+	f.inlineInput = enabled
+	return f
+}
+
+/*
+	Renders the root page for the user as inline buttons of an inline query result
+	so the menu can be shared into any chat, e.g. result.SetReplyMarkup(flow.InlineKeyboard(&q.From, ""))
+	An empty locale is detected from the user's language
+*/
+func (f *Menu) InlineKeyboard(of tb.Recipient, lang string) [][]tb.InlineButton {
+	markup := f.root.render(of, f.language(of, lang))
+	if markup == nil {
+		return nil
+	}
+	return markup.InlineKeyboard
+}
+
+/*
+	Attaches the menu to an inline message sent via an inline query
+	and shows the root page in it, pass tb.OnChosenInlineResult to get the message ID
+	The text must match the text of the message, an empty locale is detected from the user's language
+*/
+func (f *Menu) Attach(to *tb.User, inlineID, text, lang string) error {
+	return f.AttachAt(to, inlineID, text, lang, f.root)
+}
+
+/*
+	Attaches the menu to an inline message and shows a specified page in it
+	The user who has sent the inline message owns the menu
+*/
+func (f *Menu) AttachAt(to *tb.User, inlineID, text, lang string, at *Node) error {
+	if f.replyMode {
+		return ErrInlineReplyKeyboard
+	}
+	lang = f.language(to, lang)
+	d := &Dialog{Message: &tb.Message{InlineID: inlineID, Text: text}, Language: lang}
+	if old, ok := f.GetDialogByMessage(d.Message); ok {
+		d = old
+	}
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	d.Message.Text = text
+	d.Language = lang
+	msg, err := f.edit(to, d.Message, at, lang, text)
+	if err != nil {
+		return err
+	}
+	f.remember(d, d.Position, at)
+	d.Message = msg
+	d.Position = at
+	d.owner = to.Recipient()
+	f.setDialog(to.Recipient(), d)
+	f.watch(d, at)
+	return nil
+}
+
+/*
+	Creates a handler of chosen inline results that attaches the menu to the sent messages
+	Telegram sends chosen results only when inline feedback is enabled for the bot
+*/
+func (f *Menu) HandleChosen(text, lang string) func(*tb.ChosenInlineResult) {
+	return func(r *tb.ChosenInlineResult) {
+		if r.MessageID == "" {
+			// the result has no inline keyboard
+			return
+		}
+		user := r.From
+		if err := f.Attach(&user, r.MessageID, text, lang); err != nil {
+			log.Println("failed to attach", user.Recipient(), err)
+		}
+	}
+}
+
+/*
+	Starts tracking an inline message that has no dialog yet, e.g. after a restart,
+	on the page the pressed node belongs to
+	The text of such a message is unknown, so only its buttons are edited until a caption is set,
+	the owner is unknown as well and the user who has pressed the button doesn't become one
+	Only internal use is intended
+*/
+func (f *Menu) adopt(c *tb.Callback, node *Node) *Dialog {
+	page := node.prev
+	if page == nil {
+		page = f.root
+	}
+	d := &Dialog{
+		Message:  &tb.Message{InlineID: c.Message.InlineID},
+		Language: f.DetectLanguage(c.Sender),
+		Position: page,
+	}
+	f.setDialog(c.Sender.Recipient(), d)
+	return d
+}
+
+/*
+	Forgets the least recently used inline messages over the limit, the caller must hold the lock
+	Inline messages cannot be deleted, so they would be kept forever otherwise
+	Only internal use is intended
+*/
+func (f *Menu) trimInline() {
+	if f.inlineLimit < 1 {
+		return
+	}
+	inline := make([]*Dialog, 0)
+	for _, d := range f.dialogs {
+		if isInline(d.Message) {
+			inline = append(inline, d)
+		}
+	}
+	if len(inline) <= f.inlineLimit {
+		return
+	}
+	sort.Slice(inline, func(i, j int) bool {
+		return inline[i].touched.Before(inline[j].touched)
+	})
+	for _, d := range inline[:len(inline)-f.inlineLimit] {
+		f.unlink(d)
+	}
+}
+
+/*
+	Shows a page in an inline message
+	Inline messages cannot be re-sent or deleted, so they are always edited in place
+	Only internal use is intended
+*/
+func (f *Menu) editInline(to tb.Recipient, msg *tb.Message, page *Node, lang, text string) (*tb.Message, error) {
+	markup := page.render(to, lang)
+	var err error
+	if page.media != nil {
+		_, err = f.bot.Edit(msg, page.media.input(f.caption(page, lang, text)), markup)
+	} else if text == "" {
+		_, err = f.bot.EditReplyMarkup(msg, markup)
+	} else {
+		_, err = f.bot.Edit(msg, text, markup)
+	}
+	if !isEdited(err) {
+		return nil, err
+	}
+	// edits of inline messages return no message
+	return &tb.Message{InlineID: msg.InlineID, Text: text}, nil
+}
+
+/*
+	Checks if a message was sent via an inline query
+*/
+func isInline(msg *tb.Message) bool {
+	return msg != nil && msg.InlineID != ""
+}
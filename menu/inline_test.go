@@ -0,0 +1,38 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"strconv"
+	"testing"
+)
+
+func TestInlineLimit(t *testing.T) {
+	user := &tb.User{ID: 1}
+	tests := []struct {
+		limit    int
+		attaches int
+		open     int
+	}{
+		{limit: 2, attaches: 1, open: 1},
+		{limit: 2, attaches: 5, open: 2},
+		{limit: 0, attaches: 5, open: 5},
+	}
+	for _, tt := range tests {
+		f, _ := newTestMenu(t, nil)
+		f.SetInlineLimit(tt.limit).Build("en")
+		for i := 0; i < tt.attaches; i++ {
+			if err := f.Attach(user, "inline"+strconv.Itoa(i), "Hello", "en"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if open := len(f.GetDialogs(user.Recipient())); open != tt.open {
+			t.Errorf("limit %d, %d attaches: %d open; want %d", tt.limit, tt.attaches, open, tt.open)
+		}
+		for i := 0; i < tt.attaches; i++ {
+			_, ok := f.GetDialogByMessage(&tb.Message{InlineID: "inline" + strconv.Itoa(i)})
+			if want := i >= tt.attaches-tt.open; ok != want {
+				t.Errorf("limit %d, %d attaches: message %d is open %v; want %v", tt.limit, tt.attaches, i, ok, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"log"
+)
+
+/*
+	Handler of a text typed by the user in an input node
+	Returns false when the input is invalid and the user has to try again
+*/
+type InputHandler func(e *Node, m *tb.Message) bool
+
+/*
+	Creates a new input node in the flow
+	that asks the user to type a reply and passes it to the handler
+	Prompt and invalid texts are locale paths that replace the caption while waiting,
+	returnTo is a node to return to, when nil the user returns to the previous page
+	Replies arrive through Menu.Process
+*/
+func (f *Menu) NewInputNode(text, promptPath, invalidPath string, handler InputHandler, returnTo *Node) *Node {
+	return newInputNode(f, text, promptPath, invalidPath, handler, returnTo, f.root)
+}
+
+/*
+	Adds a new input node to the current node
+	Returns the current node
+*/
+func (e *Node) AddInput(text, promptPath, invalidPath string, handler InputHandler, returnTo *Node) *Node {
+	e.AddManySub([]*Node{newInputNode(e.flow, text, promptPath, invalidPath, handler, returnTo, e)})
+	return e
+}
+
+/*
+	Creates a new input node with a cancel sub node
+	Only internal use is intended
+*/
+func newInputNode(root *Menu, text, promptPath, invalidPath string, handler InputHandler, returnTo, prev *Node) *Node {
+	e := newNode(root, text, nil, prev)
+	e.kind = kindInput
+	e.question = promptPath
+	e.invalid = invalidPath
+	e.onInput = handler
+	e.returnTo = returnTo
+	no := newNode(root, DefaultCancelText, nil, e)
+	no.kind = kindConfirmNo
+	e.nodes = []*Node{no}
+	return e
+}
+
+/*
+	Checks if the user is expected to type a reply in any chat
+*/
+func (f *Menu) IsWaitingInput(of tb.Recipient) bool {
+	return f.waiting(of.Recipient(), nil) != nil
+}
+
+/*
+	Handler for input nodes that prompts the user to type a reply
+*/
+func (e *Node) handleInput(c *tb.Callback) {
+	e.flow.respond(c)
+	d, ok := e.flow.dialogOf(c)
+	if !ok {
+		log.Println(c.Sender.ID, "does not exist")
+		return
+	}
+	if d.input == nil {
+		d.caption = d.Message.Text
+	}
+	d.question = e.Translate(c, e.question)
+	d.Message.Text = d.question
+	from := d.Position
+	if !e.update(c.Sender, d, e) {
+		d.Message.Text = d.caption
+		return
+	}
+	e.flow.remember(d, from, e)
+	d.input = e
+}
+
+/*
+	Passes a typed reply to the handler and returns to the chosen page
+	Invalid replies keep the user on the input page
+	The callback stands for the reply and refers to the menu message, so the reply reaches the right dialog
+*/
+func (e *Node) receive(c *tb.Callback, d *Dialog, m *tb.Message) {
+	if r, ok := e.widget.(receiver); ok {
+		r.receive(e, c, d, m)
+		return
+	}
+	valid := true
+	if e.onInput != nil {
+		e.flow.release(c, func() { valid = e.onInput(e, m) })
+	}
+	if !valid {
+		if e.invalid != "" {
+			d.question = e.Translate(c, e.invalid)
+			if d.Message.Text != d.question {
+				d.Message.Text = d.question
+				e.update(m.Sender, d, e)
+			}
+		}
+		return
+	}
+	d.input = nil
+	e.restore(c)
+	if e.returnTo == nil {
+		e.back(c)
+		return
+	}
+	from := d.Position
+	if e.update(m.Sender, d, e.returnTo) {
+		e.flow.remember(d, from, e.returnTo)
+	}
+}
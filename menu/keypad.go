@@ -0,0 +1,155 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"log"
+	"strconv"
+	"strings"
+)
+
+/*
+	Handler of a value typed by the user on a keypad
+	Returns the same navigation results as Callback, e.g. Back or Stay
+*/
+type KeypadHandler func(e *Node, c *tb.Callback, value string) int
+
+/*
+	A numeric keypad with backspace, clear and OK buttons, e.g. for PIN codes or quantities
+	The typed value is displayed in the caption after the prompt, a locale path
+	MaxLength limits the amount of digits, zero allows any amount
+	MinLength is the amount of digits required to confirm the value
+	Mask hides the typed digits, e.g. for PIN codes
+*/
+type Keypad struct {
+	Prompt    string
+	MinLength int
+	MaxLength int
+	Mask      bool
+	Handler   KeypadHandler
+}
+
+/*
+	Creates a new keypad node in the flow
+	that opens a numeric keypad and passes the confirmed value to the handler of the keypad
+*/
+func (f *Menu) NewKeypadNode(text string, keypad *Keypad) *Node {
+	return newWidgetNode(f, text, keypad, f.root)
+}
+
+/*
+	Adds a new keypad node to the current node
+	Returns the current node
+*/
+func (e *Node) AddKeypad(text string, keypad *Keypad) *Node {
+	e.AddManySub([]*Node{newWidgetNode(e.flow, text, keypad, e)})
+	return e
+}
+
+/*
+	Renders the keypad
+*/
+func (k *Keypad) keyboard(e *Node, of tb.Recipient, lang string) [][]tb.InlineButton {
+	rows := make([][]tb.InlineButton, 0, 5)
+	for i := 1; i < 10; i += 3 {
+		rows = append(rows, []tb.InlineButton{
+			e.widgetButton(strconv.Itoa(i), "k"+strconv.Itoa(i)),
+			e.widgetButton(strconv.Itoa(i+1), "k"+strconv.Itoa(i+1)),
+			e.widgetButton(strconv.Itoa(i+2), "k"+strconv.Itoa(i+2)),
+		})
+	}
+	rows = append(rows, []tb.InlineButton{
+		e.widgetButton("⌫", "bs"),
+		e.widgetButton("0", "k0"),
+		e.widgetButton("C", "clr"),
+	}, []tb.InlineButton{
+		e.widgetButton(e.flow.tr(lang, e.flow.confirmPath), "ok"),
+	})
+	return rows
+}
+
+/*
+	Starts typing a new value
+*/
+func (k *Keypad) open(e *Node, c *tb.Callback, d *Dialog) {
+	e.flow.setState(c.Sender.Recipient(), e.stateKey(), "")
+	k.display(e, c, d, "")
+}
+
+/*
+	Edits the typed value and passes the confirmed value to the handler
+*/
+func (k *Keypad) press(e *Node, c *tb.Callback, arg string) {
+	d, ok := e.flow.dialogOf(c)
+	if !ok {
+		log.Println(c.Sender.ID, "does not exist")
+		e.flow.respond(c)
+		return
+	}
+	value, _ := e.flow.getState(c.Sender.Recipient(), e.stateKey())
+	switch {
+	case len(arg) == 2 && arg[0] == 'k' && arg[1] >= '0' && arg[1] <= '9':
+		if k.MaxLength > 0 && len(value) >= k.MaxLength {
+			e.flow.respond(c)
+			return
+		}
+		value += arg[1:]
+	case arg == "bs" && value != "":
+		value = value[:len(value)-1]
+	case arg == "clr":
+		value = ""
+	case arg == "ok":
+		if len(value) < k.MinLength || value == "" {
+			e.flow.respond(c)
+			return
+		}
+		k.confirm(e, c, d, value)
+		return
+	}
+	e.flow.respond(c)
+	e.flow.setState(c.Sender.Recipient(), e.stateKey(), value)
+	k.display(e, c, d, value)
+	e.refresh(c)
+}
+
+/*
+	Passes a confirmed value to the handler and restores the caption
+*/
+func (k *Keypad) confirm(e *Node, c *tb.Callback, d *Dialog, value string) {
+	e.flow.setState(c.Sender.Recipient(), e.stateKey(), "")
+	result := Stay
+	if k.Handler != nil {
+		e.flow.release(c, func() { result = k.Handler(e, c, value) })
+	}
+	e.flow.respond(c)
+	e.restore(c)
+	if result == Stay {
+		// the keypad is ready for a new value
+		k.display(e, c, d, "")
+		e.refresh(c)
+		return
+	}
+	e.mustUpdate = true
+	e.navigate(c, result)
+}
+
+/*
+	Displays the typed value in the caption after the prompt
+*/
+func (k *Keypad) display(e *Node, c *tb.Callback, d *Dialog, value string) {
+	if d.question == "" || d.Message.Text != d.question {
+		// the caption has been replaced since the keypad was opened
+		d.caption = d.Message.Text
+	}
+	shown := value
+	if k.Mask {
+		shown = strings.Repeat("•", len(value))
+	}
+	if shown == "" {
+		shown = "_"
+	}
+	d.question = shown
+	if k.Prompt != "" {
+		d.question = e.Translate(c, k.Prompt) + " " + shown
+	}
+	d.Message.Text = d.question
+}
@@ -0,0 +1,49 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"strings"
+	"testing"
+)
+
+func TestKeypad(t *testing.T) {
+	user := &tb.User{ID: 1}
+	tests := []struct {
+		name      string
+		keypad    Keypad
+		presses   string
+		confirmed bool
+		want      string
+	}{
+		{name: "digits", presses: "k1 k2 k3 ok", confirmed: true, want: "123"},
+		{name: "backspace", presses: "k1 k2 bs k3 ok", confirmed: true, want: "13"},
+		{name: "backspace on nothing", presses: "bs k4 ok", confirmed: true, want: "4"},
+		{name: "clear", presses: "k1 k2 clr k9 ok", confirmed: true, want: "9"},
+		{name: "empty value", presses: "ok", confirmed: false},
+		{name: "too short", keypad: Keypad{MinLength: 4}, presses: "k1 k2 k3 ok", confirmed: false},
+		{name: "long enough", keypad: Keypad{MinLength: 4}, presses: "k1 k2 k3 k4 ok", confirmed: true, want: "1234"},
+		{name: "digits over the limit", keypad: Keypad{MaxLength: 2}, presses: "k1 k2 k3 ok", confirmed: true, want: "12"},
+		{name: "unknown key", presses: "k k10 kx x k5 ok", confirmed: true, want: "5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _ := newTestMenu(t, map[string]string{"flow/pin": "PIN"})
+			confirmed, value := false, ""
+			keypad := tt.keypad
+			keypad.Handler = func(e *Node, c *tb.Callback, v string) int {
+				confirmed, value = true, v
+				return Stay
+			}
+			f.GetRoot().AddKeypad("pin", &keypad).GetFlow().Build("en")
+			e, _ := f.Find("pin")
+			d := startDialog(t, f, user)
+			f.handleCallback(newCallback(user, d.Message, e.id))
+			for _, arg := range strings.Fields(tt.presses) {
+				f.handleCallback(newCallback(user, d.Message, e.id+":"+arg))
+			}
+			if confirmed != tt.confirmed || value != tt.want {
+				t.Errorf("confirmed %v with %q; want %v with %q", confirmed, value, tt.confirmed, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package menu
+
+import (
+	"go-telegram-flow/internal/i18n"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+/*
+	Remembers a language picked by the user
+	An empty language forgets the choice
+*/
+func (f *Menu) SetUserLanguage(of tb.Recipient, lang string) *Menu {
+	f.mx.Lock()
+	if lang == "" {
+		delete(f.overrides, of.Recipient())
+	} else {
+		f.overrides[of.Recipient()] = lang
+	}
+	f.mx.Unlock()
+	return f
+}
+
+/*
+	Gets a language picked by the user
+*/
+func (f *Menu) GetUserLanguage(of tb.Recipient) (string, bool) {
+	f.mx.RLock()
+	lang, ok := f.overrides[of.Recipient()]
+	f.mx.RUnlock()
+	return lang, ok
+}
+
+/*
+	Detects a language for the user
+	A language picked by the user goes first, then the Telegram language code
+	mapped to available locales (e.g. "en-US" -> "en") and the default locale at last
+*/
+func (f *Menu) DetectLanguage(user *tb.User) string {
+	if user == nil {
+		return f.defaultLocale
+	}
+	if lang, ok := f.GetUserLanguage(user); ok {
+		return lang
+	}
+	if lang, ok := MatchLanguage(f.GetLanguages(), user.LanguageCode); ok {
+		return lang
+	}
+	return f.defaultLocale
+}
+
+/*
+	Resolves a language of a recipient when none is specified
+	Only internal use is intended
+*/
+func (f *Menu) language(to tb.Recipient, lang string) string {
+	if lang != "" {
+		return lang
+	}
+	if user, ok := to.(*tb.User); ok {
+		return f.DetectLanguage(user)
+	}
+	if lang, ok := f.GetUserLanguage(to); ok {
+		return lang
+	}
+	return f.defaultLocale
+}
+
+/*
+	Maps a Telegram language code (IETF tag) to one of available languages
+	Tries the exact code first and then the code without the region
+*/
+func MatchLanguage(available []string, code string) (string, bool) {
+	return i18n.MatchLanguage(available, code)
+}
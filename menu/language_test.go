@@ -0,0 +1,56 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"testing"
+)
+
+func TestMatchLanguage(t *testing.T) {
+	available := []string{"en", "pt_BR", "ru"}
+	tests := []struct {
+		code string
+		lang string
+		ok   bool
+	}{
+		{"en", "en", true},
+		{"en-US", "en", true},
+		{"RU", "ru", true},
+		{"pt-br", "pt_BR", true},
+		{"pt", "", false},
+		{"de", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		lang, ok := MatchLanguage(available, tt.code)
+		if lang != tt.lang || ok != tt.ok {
+			t.Errorf("MatchLanguage(%q) = %q, %v; want %q, %v", tt.code, lang, ok, tt.lang, tt.ok)
+		}
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	// paths are relative to the English locale, so "../ru" is the Russian one
+	engine := newTestEngine(t, map[string]string{"flow/a": "Alpha", "../ru/flow/a": "Альфа"})
+	f, err := NewMenuFlow("flow", nil, engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	picked := &tb.User{ID: 3, LanguageCode: "en"}
+	f.SetUserLanguage(picked, "ru")
+	tests := []struct {
+		name string
+		user *tb.User
+		want string
+	}{
+		{"regional code", &tb.User{ID: 1, LanguageCode: "ru-RU"}, "ru"},
+		{"unknown code falls back to the engine default", &tb.User{ID: 2, LanguageCode: "de"}, "en"},
+		{"no code", &tb.User{ID: 4}, "en"},
+		{"picked language", picked, "ru"},
+		{"no user", nil, "en"},
+	}
+	for _, tt := range tests {
+		if got := f.DetectLanguage(tt.user); got != tt.want {
+			t.Errorf("%s: %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+/*
+	Creates a new URL button node in the flow
+	that opens a link without calling the bot
+*/
+func (f *Menu) NewURLNode(text, url string) *Node {
+	return newLinkNode(f, text, kindURL, tb.InlineButton{URL: url}, f.root)
+}
+
+/*
+	Creates a new switch inline button node in the flow
+	that makes the user pick a chat and insert the bot's username with a query
+	If currentChat is true the query is inserted in the current chat
+*/
+func (f *Menu) NewSwitchInlineNode(text, query string, currentChat bool) *Node {
+	return newLinkNode(f, text, kindSwitchInline, switchInlineButton(query, currentChat), f.root)
+}
+
+/*
+	Creates a new login button node in the flow
+	that authorizes the user on a website
+*/
+func (f *Menu) NewLoginNode(text string, login *tb.Login) *Node {
+	return newLinkNode(f, text, kindLogin, tb.InlineButton{Login: login}, f.root)
+}
+
+/*
+	Creates a new link node
+	Only internal use is intended
+*/
+func newLinkNode(root *Menu, text string, kind int, link tb.InlineButton, prev *Node) *Node {
+	e := newNode(root, text, nil, prev)
+	e.kind = kind
+	e.link = link
+	return e
+}
+
+/*
+	Prepares a switch inline button
+	Only internal use is intended
+*/
+func switchInlineButton(query string, currentChat bool) tb.InlineButton {
+	if currentChat {
+		return tb.InlineButton{InlineQueryChat: query}
+	}
+	return tb.InlineButton{InlineQuery: query}
+}
+
+/*
+	Adds a new URL button node to the current node
+	Returns the current node
+*/
+func (e *Node) AddURL(text, url string) *Node {
+	e.AddManySub([]*Node{newLinkNode(e.flow, text, kindURL, tb.InlineButton{URL: url}, e)})
+	return e
+}
+
+/*
+	Adds a new switch inline button node to the current node
+	Returns the current node
+*/
+func (e *Node) AddSwitchInline(text, query string, currentChat bool) *Node {
+	e.AddManySub([]*Node{newLinkNode(e.flow, text, kindSwitchInline, switchInlineButton(query, currentChat), e)})
+	return e
+}
+
+/*
+	Adds a new login button node to the current node
+	Returns the current node
+*/
+func (e *Node) AddLogin(text string, login *tb.Login) *Node {
+	e.AddManySub([]*Node{newLinkNode(e.flow, text, kindLogin, tb.InlineButton{Login: login}, e)})
+	return e
+}
+
+/*
+	Checks if the node is a link (URL, switch inline or login button)
+	Link nodes never trigger callbacks
+*/
+func (e *Node) IsLink() bool {
+	return e.kind == kindURL || e.kind == kindSwitchInline || e.kind == kindLogin
+}
+
+/*
+	Renders an inline button of a link node with a localized text
+*/
+func (e *Node) linkButton(text string) tb.InlineButton {
+	btn := e.link
+	btn.Text = text
+	if btn.Login != nil {
+		login := *btn.Login
+		btn.Login = &login
+	}
+	return btn
+}
@@ -0,0 +1,191 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"log"
+	"time"
+)
+
+/*
+	Provider of a fresh caption of a live page for the user
+	An empty caption keeps the current one
+	It is called while the page is rendered, so it must not change the menu, e.g. with Menu.SetCaption
+*/
+type CaptionProvider func(e *Node, of tb.Recipient) string
+
+/*
+	The default shortest interval between two refreshes of a menu
+*/
+const DefaultRefreshLimit = 2 * time.Second
+
+/*
+	The default time a live page keeps refreshing after the user's last click
+*/
+const DefaultLiveTimeout = 15 * time.Minute
+
+/*
+	Options of a page that refreshes itself
+*/
+type live struct {
+	interval time.Duration
+	caption  CaptionProvider
+}
+
+/*
+	An id of a user that refreshes are rendered for
+*/
+type userID string
+
+func (u userID) Recipient() string {
+	return string(u)
+}
+
+/*
+	Makes the page refresh its caption and markup while the user is on it
+	The page refreshes every interval and whenever Menu.Publish is called for the user,
+	a zero interval refreshes the page only when an update is published
+	Menus with reply keyboards display the page as it was when opened
+	Returns the current node
+*/
+func (e *Node) SetLive(interval time.Duration, caption CaptionProvider) *Node {
+	e.live = &live{interval: interval, caption: caption}
+	return e
+}
+
+/*
+	Checks if the page refreshes itself
+*/
+func (e *Node) IsLive() bool {
+	return e.live != nil
+}
+
+/*
+	Sets the shortest interval between two refreshes of a menu
+	Refreshes requested earlier are postponed
+*/
+func (f *Menu) SetRefreshLimit(limit time.Duration) *Menu {
+	f.refreshLimit = limit
+	return f
+}
+
+/*
+	Sets the time a live page keeps refreshing after the user's last click
+	Zero or a negative timeout refreshes the page as long as the user is on it
+*/
+func (f *Menu) SetLiveTimeout(timeout time.Duration) *Menu {
+	f.liveTimeout = timeout
+	return f
+}
+
+/*
+	Refreshes every live page the user is on, e.g. when the status of an order changes
+	Pages are refreshed in the background, so it is safe to publish from an endpoint
+	Menus with reply keyboards are never refreshed, so nothing is published to them
+*/
+func (f *Menu) Publish(to tb.Recipient) *Menu {
+	if f.replyMode {
+		return f
+	}
+	for _, d := range f.GetDialogs(to.Recipient()) {
+		go func(d *Dialog) {
+			d.mx.Lock()
+			page := d.Position
+			d.mx.Unlock()
+			if page != nil && page.IsLive() {
+				f.refresh(d, page)
+			}
+		}(d)
+	}
+	return f
+}
+
+/*
+	Starts refreshing a live page the user has opened
+	Only internal use is intended
+*/
+func (f *Menu) watch(d *Dialog, page *Node) {
+	f.mx.Lock()
+	d.touched = time.Now()
+	if f.replyMode || page.live == nil || page.live.interval <= 0 || d.watching == page {
+		f.mx.Unlock()
+		return
+	}
+	d.watching = page
+	f.mx.Unlock()
+	go func() {
+		ticker := time.NewTicker(page.live.interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if !f.refresh(d, page) {
+				break
+			}
+		}
+		f.mx.Lock()
+		if d.watching == page {
+			d.watching = nil
+		}
+		f.mx.Unlock()
+	}()
+}
+
+/*
+	Checks if a live page is still displayed in an open dialog that hasn't expired
+	The caller must hold the lock of the dialog
+	Only internal use is intended
+*/
+func (f *Menu) isLive(d *Dialog, page *Node) bool {
+	f.mx.RLock()
+	defer f.mx.RUnlock()
+	if f.dialogs[d.key] != d || d.Position != page {
+		// the dialog is closed or the user has left the page
+		return false
+	}
+	return f.liveTimeout <= 0 || time.Since(d.touched) < f.liveTimeout
+}
+
+/*
+	Renders a live page again, refreshes that come too often are postponed
+	Menus with reply keyboards are never refreshed since every render would post a new message
+	The page is rendered for the owner of the menu
+	Returns false when the page is no longer live
+	Only internal use is intended
+*/
+func (f *Menu) refresh(d *Dialog, page *Node) bool {
+	if f.replyMode {
+		return false
+	}
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	if !f.isLive(d, page) {
+		return false
+	}
+	f.mx.Lock()
+	if wait := f.refreshLimit - time.Since(d.refreshed); wait > 0 {
+		if !d.pending {
+			d.pending = true
+			time.AfterFunc(wait, func() {
+				f.mx.Lock()
+				d.pending = false
+				f.mx.Unlock()
+				f.refresh(d, page)
+			})
+		}
+		f.mx.Unlock()
+		return true
+	}
+	d.refreshed = time.Now()
+	f.mx.Unlock()
+	of := userID(d.owner)
+	if d.owner == "" {
+		of = userID(d.user)
+	}
+	f.pageCaption(d, page, of)
+	msg, err := f.edit(of, d.Message, page, d.Language, d.Message.Text)
+	if err != nil {
+		log.Println("failed to refresh", d.user, err)
+		return true
+	}
+	d.Message = msg
+	f.reindex(d)
+	return true
+}
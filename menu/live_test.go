@@ -0,0 +1,157 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+	Collects ids of users that live captions are rendered for
+	Every render is announced on the channel
+*/
+type captionLog struct {
+	mx      sync.Mutex
+	ids     []string
+	renders chan struct{}
+}
+
+func newCaptionLog() *captionLog {
+	return &captionLog{renders: make(chan struct{}, 1024)}
+}
+
+func (l *captionLog) provider(e *Node, of tb.Recipient) string {
+	l.mx.Lock()
+	l.ids = append(l.ids, of.Recipient())
+	l.mx.Unlock()
+	select {
+	case l.renders <- struct{}{}:
+	default:
+	}
+	return "Status " + time.Now().String()
+}
+
+/*
+	Waits for a number of renders, the test fails when they do not come
+*/
+func (l *captionLog) wait(t *testing.T, renders int) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for i := 0; i < renders; i++ {
+		select {
+		case <-l.renders:
+		case <-timeout:
+			t.Fatalf("%d of %d renders", i, renders)
+		}
+	}
+}
+
+func (l *captionLog) rendered() []string {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	return append([]string(nil), l.ids...)
+}
+
+/*
+	Closes the dialogs of the menu when the test ends, so their pages stop refreshing
+*/
+func stopLive(t *testing.T, f *Menu) {
+	t.Cleanup(func() {
+		f.mx.Lock()
+		defer f.mx.Unlock()
+		for _, d := range f.dialogs {
+			f.unlink(d)
+		}
+	})
+}
+
+func TestLiveReplyMode(t *testing.T) {
+	user := &tb.User{ID: 1}
+	f, api := newTestMenu(t, nil)
+	log := newCaptionLog()
+	f.SetReplyKeyboard(true).SetRefreshLimit(0).SetLiveTimeout(time.Second)
+	f.GetRoot().SetLive(5*time.Millisecond, log.provider)
+	f.Build("en")
+	stopLive(t, f)
+	startDialog(t, f, user)
+	started := len(log.rendered())
+	// nothing is published to reply keyboards, not even in the background
+	f.Publish(user)
+	sent := 0
+	for _, method := range api.methods() {
+		if strings.HasPrefix(method, "send") {
+			sent++
+		}
+	}
+	if sent != 1 {
+		t.Errorf("%d messages sent; want only the menu", sent)
+	}
+	if rendered := log.rendered(); len(rendered) != started {
+		t.Errorf("%d renders; want %d at the start", len(rendered), started)
+	}
+}
+
+func TestLiveRendersForOwner(t *testing.T) {
+	owner := &tb.User{ID: 1}
+	group := &tb.Chat{ID: -100, Type: tb.ChatGroup}
+	f, _ := newTestMenu(t, nil)
+	log := newCaptionLog()
+	f.SetRefreshLimit(0).SetLiveTimeout(time.Minute)
+	f.GetRoot().SetLive(5*time.Millisecond, log.provider)
+	f.Build("en")
+	stopLive(t, f)
+	if err := f.StartFor(group, owner, "Hello", "en"); err != nil {
+		t.Fatal(err)
+	}
+	// the start and a refresh
+	log.wait(t, 2)
+	for _, id := range log.rendered() {
+		if id != owner.Recipient() {
+			t.Errorf("rendered for %q; want %q", id, owner.Recipient())
+		}
+	}
+}
+
+func TestLiveWithClicks(t *testing.T) {
+	user := &tb.User{ID: 1}
+	f, _ := newTestMenu(t, map[string]string{"flow/t": "Toggle", "flow/move": "Move"})
+	log := newCaptionLog()
+	f.SetRefreshLimit(0).SetLiveTimeout(time.Minute)
+	f.GetRoot().SetLive(time.Millisecond, log.provider).
+		AddToggle("t", nil).
+		Add("move", func(e *Node, c *tb.Callback) int {
+			// endpoints change the menu while it is refreshed
+			e.SetCaption(c, "Moving")
+			f.MoveTo(c.Sender, "Moved", "", f.GetRoot())
+			return Stay
+		})
+	f.Build("en")
+	toggle, _ := f.Find("t")
+	move, _ := f.Find("move")
+	stopLive(t, f)
+	d := startDialog(t, f, user)
+	log.wait(t, 2)
+
+	// refreshes keep running while the user presses buttons and the bot changes the caption,
+	// the race detector reports unsynchronized access to the dialog
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			d.mx.Lock()
+			msg := &tb.Message{ID: d.Message.ID, Chat: d.Message.Chat}
+			d.mx.Unlock()
+			f.handleCallback(newCallback(user, msg, toggle.id))
+			f.handleCallback(newCallback(user, msg, move.id))
+			f.SetCaption(user, "Caption %d", i)
+			f.Publish(user)
+		}(i)
+	}
+	wg.Wait()
+	if toggle.IsChecked(user) {
+		t.Error("the toggle pressed an even number of times is checked")
+	}
+}
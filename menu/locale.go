@@ -0,0 +1,123 @@
+package menu
+
+import (
+	"go-telegram-flow/internal/i18n"
+)
+
+/*
+	A translation that is missing or empty in a specific language
+*/
+type MissingText = i18n.MissingText
+
+/*
+	Error that lists every missing or empty translation of a menu
+*/
+type ValidationError = i18n.ValidationError
+
+/*
+	Sets a default locale of the menu
+*/
+func (f *Menu) SetDefaultLocale(lang string) *Menu {
+	f.defaultLocale = lang
+	return f
+}
+
+/*
+	Uses translations of the default locale for missing or empty texts
+*/
+func (f *Menu) SetFallback(enabled bool) *Menu {
+	f.fallback = enabled
+	return f
+}
+
+/*
+	Gets all languages of the attached locale engine in a stable order
+*/
+func (f *Menu) GetLanguages() []string {
+	return i18n.Languages(f.engine)
+}
+
+/*
+	Builds the flow for every language of the locale engine
+*/
+func (f *Menu) BuildAll() *Menu {
+	for _, lang := range f.GetLanguages() {
+		f.Build(lang)
+	}
+	return f
+}
+
+/*
+	Checks that every text the menu can display has a translation in the specified languages
+	or in all languages of the locale engine when none are specified
+	Labels, captions, questions, prompts and alerts of the nodes are checked along with the texts of the menu
+	Returns a *ValidationError listing missing and empty translations, every path is listed once per language
+*/
+func (f *Menu) Validate(langs ...string) error {
+	if len(langs) < 1 {
+		langs = f.GetLanguages()
+	}
+	var missing []MissingText
+	for _, lang := range langs {
+		checked := make(map[string]bool)
+		for _, path := range f.paths() {
+			if path == "" || checked[path] {
+				continue
+			}
+			checked[path] = true
+			missing = i18n.Check(missing, f.engine, lang, path)
+		}
+	}
+	if len(missing) > 0 {
+		return &ValidationError{Missing: missing}
+	}
+	return nil
+}
+
+/*
+	Lists every locale path the menu can translate, paths may repeat
+	Texts of widgets that have English defaults are optional and not listed
+	Only internal use is intended
+*/
+func (f *Menu) paths() []string {
+	paths := []string{f.deniedPath, f.stalePath}
+	if f.ownerOnly {
+		paths = append(paths, f.foreignPath)
+	}
+	f.treeMx.RLock()
+	defer f.treeMx.RUnlock()
+	f.root.walk(f.id, func(e *Node, path string) {
+		paths = append(paths, e.labelPath(path), e.question, e.invalid, e.deniedPath)
+		if e.media != nil {
+			paths = append(paths, e.media.Caption)
+		}
+		switch w := e.widget.(type) {
+		case *Rating:
+			paths = append(paths, w.Prompt)
+		case *Keypad:
+			paths = append(paths, w.Prompt)
+		}
+	})
+	return paths
+}
+
+/*
+	Translates a locale path in a specified locale
+	Falls back to the default locale when enabled
+	Only internal use is intended
+*/
+func (f *Menu) tr(lang, path string) string {
+	return i18n.Translate(f.engine, lang, f.defaultLocale, f.fallback, path)
+}
+
+/*
+	Visits every node below the current one with its locale path
+	Paths are computed the same way as they are during the build
+*/
+func (e *Node) walk(basePath string, visit func(e *Node, path string)) {
+	for _, child := range e.nodes {
+		path := basePath + "/" + child.text
+		visit(child, path)
+		child.walk(path, visit)
+	}
+}
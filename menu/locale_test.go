@@ -0,0 +1,81 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		ownerOnly bool
+		texts     map[string]string
+		missing   map[string]bool
+	}{
+		{
+			name: "every user-facing path is checked once",
+			texts: map[string]string{
+				"flow/c1":     "First",
+				"flow/c2":     "Second",
+				"flow/in":     "Input",
+				"flow/g":      "Guarded",
+				"flow/prompt": "Type it",
+				"flow/stale":  "",
+			},
+			missing: map[string]bool{
+				"flow/denied":  false,
+				"flow/stale":   true,
+				"flow/confirm": false,
+				"flow/cancel":  false,
+				"flow/q1":      false,
+				"flow/invalid": false,
+				"flow/nope":    false,
+			},
+		},
+		{
+			name:      "the foreign alert is checked when only owners may press",
+			ownerOnly: true,
+			texts: map[string]string{
+				"flow/c1":      "First",
+				"flow/c2":      "Second",
+				"flow/in":      "Input",
+				"flow/g":       "Guarded",
+				"flow/prompt":  "Type it",
+				"flow/stale":   "Outdated",
+				"flow/denied":  "Denied",
+				"flow/confirm": "OK",
+				"flow/cancel":  "Cancel",
+				"flow/q1":      "Sure?",
+				"flow/invalid": "Try again",
+				"flow/nope":    "Nope",
+			},
+			missing: map[string]bool{"flow/foreign": false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _ := newTestMenu(t, tt.texts)
+			f.SetOwnerOnly(tt.ownerOnly).GetRoot().
+				AddConfirm("c1", nil, "flow/q1").
+				AddConfirm("c2", nil, "").
+				AddInput("in", "flow/prompt", "flow/invalid", nil, nil).
+				AddManySub([]*Node{f.NewNode("g", nil).SetGuard(func(e *Node, of tb.Recipient) bool { return false }, "flow/nope")})
+			err := f.Validate("en")
+			if err == nil {
+				t.Fatal("no validation error")
+			}
+			reported := err.(*ValidationError).Missing
+			if len(reported) != len(tt.missing) {
+				t.Errorf("%d paths reported; want %d:\n%v", len(reported), len(tt.missing), err)
+			}
+			for _, m := range reported {
+				empty, ok := tt.missing[m.Path]
+				if !ok {
+					t.Errorf("unexpected %q", m.Path)
+				} else if m.Empty != empty {
+					t.Errorf("%q empty %v; want %v", m.Path, m.Empty, empty)
+				}
+			}
+		})
+	}
+}
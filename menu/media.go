@@ -0,0 +1,189 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	MediaPhoto = iota + 1
+	MediaVideo
+	MediaDocument
+)
+
+/*
+	Media that is displayed on a page instead of a plain text message
+	File may be a file ID (tb.File{FileID: ...}), a local file (tb.FromDisk) or a URL (tb.FromURL)
+	Caption is a locale path, when empty the menu text is used as the caption
+*/
+type Media struct {
+	Kind    int
+	File    tb.File
+	Caption string
+}
+
+/*
+	Attaches a photo to the page
+	Returns the current node
+*/
+func (e *Node) SetPhoto(file tb.File, caption string) *Node {
+	return e.SetMedia(&Media{Kind: MediaPhoto, File: file, Caption: caption})
+}
+
+/*
+	Attaches a video to the page
+	Returns the current node
+*/
+func (e *Node) SetVideo(file tb.File, caption string) *Node {
+	return e.SetMedia(&Media{Kind: MediaVideo, File: file, Caption: caption})
+}
+
+/*
+	Attaches a document to the page
+	Returns the current node
+*/
+func (e *Node) SetDocument(file tb.File, caption string) *Node {
+	return e.SetMedia(&Media{Kind: MediaDocument, File: file, Caption: caption})
+}
+
+/*
+	Attaches media to the page, nil media turns the page back into text
+	Returns the current node
+*/
+func (e *Node) SetMedia(media *Media) *Node {
+	e.media = media
+	return e
+}
+
+/*
+	Get media attached to the page
+*/
+func (e *Node) GetMedia() *Media {
+	return e.media
+}
+
+/*
+	Creates a sendable media with a caption
+*/
+func (m *Media) input(caption string) tb.InputMedia {
+	switch m.Kind {
+	case MediaVideo:
+		return &tb.Video{File: m.File, Caption: caption}
+	case MediaDocument:
+		return &tb.Document{File: m.File, Caption: caption}
+	}
+	return &tb.Photo{File: m.File, Caption: caption}
+}
+
+/*
+	Remembers a file ID of uploaded media so the file is uploaded only once
+*/
+func (m *Media) remember(msg *tb.Message) {
+	if m.File.InCloud() || msg == nil {
+		return
+	}
+	switch {
+	case msg.Photo != nil:
+		m.File = tb.File{FileID: msg.Photo.FileID}
+	case msg.Video != nil:
+		m.File = tb.File{FileID: msg.Video.FileID}
+	case msg.Document != nil:
+		m.File = tb.File{FileID: msg.Document.FileID}
+	}
+}
+
+/*
+	Checks if a message carries media
+*/
+func isMedia(msg *tb.Message) bool {
+	return msg != nil && (msg.Photo != nil || msg.Video != nil || msg.Document != nil)
+}
+
+/*
+	Resolves a caption of a media page in a specified locale
+*/
+func (f *Menu) caption(page *Node, lang, text string) string {
+	if page.media == nil || page.media.Caption == "" {
+		return text
+	}
+	return f.tr(lang, page.media.Caption)
+}
+
+/*
+	Sends a page to a chat as a new message rendered for the user
+	The menu text is kept in the message text for both text and media pages
+	Only internal use is intended
+*/
+func (f *Menu) send(to, of tb.Recipient, page *Node, lang, text string) (*tb.Message, error) {
+	markup := page.render(of, lang)
+	var what interface{} = text
+	if page.media != nil {
+		what = page.media.input(f.caption(page, lang, text))
+	}
+	msg, err := f.bot.Send(to, what, markup, tb.Silent)
+	if err != nil {
+		return nil, err
+	}
+	if page.media != nil {
+		f.mx.Lock()
+		page.media.remember(msg)
+		f.mx.Unlock()
+	}
+	msg.Text = text
+	return msg, nil
+}
+
+/*
+	Shows a page rendered for the user in the menu message
+	Switching between text and media or using reply keyboards re-sends the menu to the same chat
+	except for inline messages that are always edited
+	A message that is gone is re-sent as well, unchanged messages are left as is
+	Only internal use is intended
+*/
+func (f *Menu) edit(of tb.Recipient, msg *tb.Message, page *Node, lang, text string) (*tb.Message, error) {
+	if isInline(msg) {
+		return f.editInline(of, msg, page, lang, text)
+	}
+	// the menu stays in its chat, e.g. a group, whoever has pressed the button
+	var to tb.Recipient = of
+	if msg.Chat != nil {
+		to = msg.Chat
+	}
+	if f.replyMode {
+		// reply keyboards cannot be edited, so the superseded menu is deleted
+		newMsg, err := f.send(to, of, page, lang, text)
+		if err != nil {
+			return nil, err
+		}
+		f.bot.Delete(msg)
+		return newMsg, nil
+	}
+	if isMedia(msg) != (page.media != nil) {
+		newMsg, err := f.send(to, of, page, lang, text)
+		if err != nil {
+			return nil, err
+		}
+		f.bot.Delete(msg)
+		return newMsg, nil
+	}
+	markup := page.render(of, lang)
+	var what interface{} = text
+	if page.media != nil {
+		what = page.media.input(f.caption(page, lang, text))
+	}
+	newMsg, err := f.bot.Edit(msg, what, markup)
+	if IsNotModified(err) {
+		newMsg, err = msg, nil
+	} else if IsMessageGone(err) {
+		return f.send(to, of, page, lang, text)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if page.media != nil {
+		f.mx.Lock()
+		page.media.remember(newMsg)
+		f.mx.Unlock()
+	}
+	newMsg.Text = text
+	return newMsg, nil
+}
@@ -0,0 +1,65 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"testing"
+)
+
+func TestEditStaysInChat(t *testing.T) {
+	user := &tb.User{ID: 1, LanguageCode: "en"}
+	group := &tb.Chat{ID: -100, Type: tb.ChatGroup}
+	tests := []struct {
+		name    string
+		fail    string
+		media   *Media
+		methods []string
+	}{
+		{
+			name:    "edited in place",
+			methods: []string{"answerCallbackQuery", "editMessageText"},
+		},
+		{
+			name:    "gone message is sent again",
+			fail:    "Bad Request: message to edit not found",
+			methods: []string{"answerCallbackQuery", "editMessageText", "sendMessage"},
+		},
+		{
+			name:    "media replaces text",
+			media:   &Media{Kind: MediaPhoto, File: tb.File{FileID: "photo"}},
+			methods: []string{"answerCallbackQuery", "sendPhoto", "deleteMessage"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, api := newTestMenu(t, map[string]string{"flow/a": "Alpha"})
+			f.GetRoot().AddWith("a", nil, f.NewBackNode("back")).GetFlow().Build("en")
+			a, _ := f.Find("a")
+			a.SetMedia(tt.media)
+			if err := f.Start(group, "Hello", "en"); err != nil {
+				t.Fatal(err)
+			}
+			d, _ := f.GetDialog(group.Recipient())
+			if tt.fail != "" {
+				api.fail["editMessageText"] = tt.fail
+			}
+			api.reset()
+			f.handleCallback(newCallback(user, d.Message, a.id))
+			methods := api.methods()
+			if len(methods) != len(tt.methods) {
+				t.Fatalf("called %v; want %v", methods, tt.methods)
+			}
+			for i, method := range tt.methods {
+				if methods[i] != method {
+					t.Fatalf("called %v; want %v", methods, tt.methods)
+				}
+				call, _ := api.last(method)
+				if chat := call.params["chat_id"]; method != "answerCallbackQuery" && chat != group.Recipient() {
+					t.Errorf("%s went to %q; want %q", method, chat, group.Recipient())
+				}
+			}
+			if d.Position != a {
+				t.Errorf("position %q; want %q", d.Position.path, a.path)
+			}
+		})
+	}
+}
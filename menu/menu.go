@@ -10,9 +10,12 @@ import (
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/tucnak/tr"
+	"go-telegram-flow/internal/i18n"
 	tb "gopkg.in/tucnak/telebot.v2"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 /*
@@ -21,41 +24,144 @@ import (
 type Menu struct {
 	id            string
 	serial        uint32
+	texts         uint32
 	root          *Node
 	bot           *tb.Bot
 	dialogs       map[string]*Dialog
+	users         map[string][]*Dialog
+	active        map[string]*Dialog
+	held          map[*tb.Callback]*Dialog
+	dialogLimit   int
+	inlineLimit   int
+	inlineInput   bool
 	defaultLocale string
 	engine        *tr.Engine
+	historyLimit  int
+	responses     map[string]*tb.CallbackResponse
+	store         StateStore
+	marks         map[string]Marks
+	replyMode     bool
+	deniedPath    string
+	fallback      bool
+	overrides     map[string]string
+	confirmPath   string
+	cancelPath    string
+	stalePath     string
+	ownerOnly     bool
+	foreignPath   string
+	calendarPath  string
+	cartPath      string
+	recordsPath   string
+	ratingPath    string
+	refreshLimit  time.Duration
+	liveTimeout   time.Duration
+	typeToSearch  bool
+	searchLimit   int
+	searchPath    string
+	results       *Node
 	mx            sync.RWMutex
+	treeMx        sync.RWMutex
 }
 
 /*
 	A dialog is an abstract piece that holds a menu message sent by the bot
 	and a language that the interface is displayed
+	History keeps the pages the user actually visited before the current position
+	Clicks, replies and refreshes of a dialog are processed one at a time,
+	endpoints and handlers run outside of that, so they may change the menu with its public methods
 */
 type Dialog struct {
-	Message  *tb.Message
-	Language string
-	Position *Node
+	Message   *tb.Message
+	Language  string
+	Position  *Node
+	History   []*Node
+	State     map[string]string
+	caption   string
+	question  string
+	input     *Node
+	key       string
+	user      string
+	owner     string
+	operators map[string]bool
+	touched   time.Time
+	refreshed time.Time
+	pending   bool
+	watching  *Node
+	mx        sync.Mutex
 }
 
+/*
+	The default amount of pages remembered in a dialog history
+*/
+const DefaultHistoryLimit = 16
+
+/*
+	The default amount of menus a user can have open at the same time
+*/
+const DefaultDialogLimit = 1
+
+/*
+	The default locale path (relative to the menu id) of the alert shown on rejected clicks
+*/
+const DefaultDeniedText = "denied"
+
+/*
+	The default locale path (relative to the menu id) of the notification shown
+	when a button of an outdated menu message is pressed
+*/
+const DefaultStaleText = "stale"
+
+var ErrNodeNotFound = errors.New("node not found")
+
 /*
 	Creates a new flow and initializes the specified locale directory
+	The default locale of the engine becomes the default locale of the menu
 	Warning! When setting a id treat it gently, like picking a directory name, same rules applies.
 	It will fail without a notice if you put special characters or symbols (except for underscore) in it.
 	Suggested names: flow1, flow_1, MyFlow
 */
 func NewMenuFlow(id string, bot *tb.Bot, engine *tr.Engine) (*Menu, error) {
 	f := &Menu{
-		id:      id,
-		serial:  0,
-		bot:     bot,
-		dialogs: make(map[string]*Dialog),
-		engine:  engine,
-		mx:      sync.RWMutex{},
+		id:            id,
+		serial:        0,
+		bot:           bot,
+		dialogs:       make(map[string]*Dialog),
+		users:         make(map[string][]*Dialog),
+		active:        make(map[string]*Dialog),
+		held:          make(map[*tb.Callback]*Dialog),
+		dialogLimit:   DefaultDialogLimit,
+		inlineLimit:   DefaultInlineLimit,
+		engine:        engine,
+		defaultLocale: i18n.DefaultLocale(engine),
+		historyLimit:  DefaultHistoryLimit,
+		responses:     make(map[string]*tb.CallbackResponse),
+		marks:         make(map[string]Marks),
+		deniedPath:    id + "/" + DefaultDeniedText,
+		overrides:     make(map[string]string),
+		confirmPath:   id + "/" + DefaultConfirmText,
+		cancelPath:    id + "/" + DefaultCancelText,
+		stalePath:     id + "/" + DefaultStaleText,
+		foreignPath:   id + "/" + DefaultForeignText,
+		calendarPath:  id + "/" + DefaultCalendarText,
+		cartPath:      id + "/" + DefaultCartText,
+		recordsPath:   id + "/" + DefaultRecordsText,
+		ratingPath:    id + "/" + DefaultRatingText,
+		refreshLimit:  DefaultRefreshLimit,
+		liveTimeout:   DefaultLiveTimeout,
+		searchLimit:   DefaultSearchLimit,
+		searchPath:    id + "/" + DefaultSearchText,
+		mx:            sync.RWMutex{},
 	}
 	atomic.StoreUint32(&f.serial, 0)
 	f.root = &Node{id: id + "_root", flow: f, mustUpdate: false, markups: make(map[string]*tb.ReplyMarkup)}
+	// search results are a page of their own outside of the tree
+	f.results = newWidgetNode(f, DefaultSearchText, &results{}, nil)
+	f.results.id = id + "_search"
+	if bot != nil {
+		// a single handler serves every button of the menu
+		// so rebuilding the menu never registers new handlers
+		bot.Handle("\f"+f.unique(), f.handleCallback)
+	}
 	return f, nil
 }
 
@@ -73,6 +179,15 @@ func (f *Menu) CountNodes() int {
 	return int(atomic.LoadUint32(&f.serial))
 }
 
+/*
+	Sets the amount of pages remembered in a dialog history
+	Zero or a negative limit disables the history
+*/
+func (f *Menu) SetHistoryLimit(limit int) *Menu {
+	f.historyLimit = limit
+	return f
+}
+
 /*
 	Get attached Telegram bot
 */
@@ -88,48 +203,50 @@ func (f *Menu) GetRoot() *Node {
 }
 
 /*
-	Retrieves a dialog by user id
+	Finds a node by its locale path, e.g. "flow1/order/pizza" or "order/pizza"
+	An empty path or the menu id addresses the root node
 */
-func (f *Menu) GetDialog(id string) (*Dialog, bool) {
-	f.mx.RLock()
-	d, ok := f.dialogs[id]
-	f.mx.RUnlock()
-	return d, ok
-}
-
-/*
-	Sets a dialog by a user id
-	Only internal use is intended
-*/
-func (f *Menu) setDialog(id string, dialog *Dialog) {
-	f.mx.Lock()
-	f.dialogs[id] = dialog
-	f.mx.Unlock()
+func (f *Menu) Find(path string) (*Node, bool) {
+	path = strings.Trim(path, "/")
+	if path == f.id {
+		return f.root, true
+	}
+	path = strings.TrimPrefix(path, f.id+"/")
+	f.treeMx.RLock()
+	defer f.treeMx.RUnlock()
+	return f.root.Find(path)
 }
 
 /*
-	Deletes a dialog by a user id
-	Only internal use is intended
+	Search for a node with ID
 */
-func (f *Menu) deleteDialog(id string) {
-	f.mx.Lock()
-	delete(f.dialogs, id)
-	f.mx.Unlock()
+func (f *Menu) Search(nodeId string) (*Node, bool) {
+	if f.root.id == nodeId {
+		return f.root, true
+	}
+	if f.results.id == nodeId {
+		return f.results, true
+	}
+	f.treeMx.RLock()
+	defer f.treeMx.RUnlock()
+	return f.root.Search(nodeId)
 }
 
 /*
-	Sets a new caption for the menu
+	Sets a new caption for the menu the user is interacting with
 	The caption will be updated right away
 	Params are automatically placed in the text if provided
 */
 func (f *Menu) SetCaption(recipient tb.Recipient, text string, params ...interface{}) *Menu {
-	if d, ok := f.GetDialog(recipient.Recipient()); ok {
+	if d, ok := f.GetActiveDialog(recipient.Recipient()); ok {
+		d.mx.Lock()
+		defer d.mx.Unlock()
 		if len(params) > 0 {
 			text = fmt.Sprintf(text, params...)
 		}
 		if d.Message.Text != text {
 			d.Message.Text = text
-			d.Position.update(recipient, d, d.Position.GetMarkup(d.Language))
+			d.Position.update(recipient, d, d.Position)
 		}
 	}
 	return f
@@ -149,6 +266,13 @@ func (f *Menu) HandleBack(e *Node, c *tb.Callback) int {
 	return Back
 }
 
+/*
+	Helper handler for home buttons
+*/
+func (f *Menu) HandleHome(e *Node, c *tb.Callback) int {
+	return Home
+}
+
 /*
 	Creates a new node in the flow
 */
@@ -164,78 +288,203 @@ func (f *Menu) NewBackNode(text string) *Node {
 	return newNode(f, text, f.HandleBack, f.root)
 }
 
+/*
+	Creates a new home button node in the flow
+	that takes a user to the root page and clears the history
+*/
+func (f *Menu) NewHomeNode(text string) *Node {
+	return newNode(f, text, f.HandleHome, f.root)
+}
+
 /*
 	Builds the flow for a specified locale
+	The flow can be rebuilt at any time, e.g. after inserting or removing nodes,
+	menus that are already sent keep working
 */
 func (f *Menu) Build(lang string) *Menu {
+	f.treeMx.Lock()
 	f.root.build(f.id, lang)
+	f.results.build(f.id+"/"+DefaultSearchText, lang)
+	f.treeMx.Unlock()
 	return f
 }
 
+/*
+	A unique callback endpoint of the menu
+*/
+func (f *Menu) unique() string {
+	return f.id + uniquePrefix
+}
+
+/*
+	A default handler that aggregates all the menu buttons
+	and finds the pressed node by the callback data
+*/
+func (f *Menu) handleCallback(c *tb.Callback) {
+	id, arg := splitData(c.Data)
+	node, ok := f.Search(id)
+	if !ok {
+		// the node was removed after the menu had been sent
+		f.respond(c)
+		return
+	}
+	if isInline(c.Message) {
+		if _, ok := f.GetDialogByMessage(c.Message); !ok && !f.ownerOnly {
+			// the owner of an unknown inline message cannot be told,
+			// so it is adopted only when anyone may press its buttons
+			f.adopt(c, node)
+		}
+	} else if f.isStale(c) {
+		// the button belongs to an older menu message
+		f.root.Notify(c, f.stalePath)
+		f.respond(c)
+		return
+	}
+	if f.rejectForeign(c) {
+		// someone else's menu
+		return
+	}
+	if d, ok := f.dialogOf(c); ok {
+		f.activate(c.Sender, d)
+		defer f.hold(c, d)()
+	}
+	if arg != "" && node.IsWidget() {
+		node.pressWidget(c, arg)
+		return
+	}
+	node.dispatch(c)
+}
+
 /*
 	Sends a new instance of a menu to a user with a specified locale
-	Tries to delete the old menu before sending a new one
+	An empty locale is detected from the user's language
+	Deletes the oldest menus of the user when the limit of open menus is reached,
+	the new menu starts with a copy of the state of the menu the user has used last
 */
 func (f *Menu) Start(to tb.Recipient, text, lang string) error {
-	if d, ok := f.GetDialog(to.Recipient()); ok {
-		f.bot.Delete(d.Message)
-	}
-	msg, err := f.bot.Send(to, text, f.root.markups[lang], tb.Silent)
+	return f.StartFor(to, to, text, lang)
+}
+
+/*
+	Sends a new instance of a menu to a chat on behalf of the user who opened it, e.g. to a group
+	The menu is rendered for the owner and the limit of open menus applies to the chat
+*/
+func (f *Menu) StartFor(to, owner tb.Recipient, text, lang string) error {
+	lang = f.language(owner, lang)
+	state := f.copyState(owner.Recipient())
+	f.evict(to)
+	msg, err := f.send(to, owner, f.root, lang, text)
 	if err != nil {
 		return err
 	}
-	f.setDialog(to.Recipient(), &Dialog{Message: msg, Language: lang, Position: f.root})
+	d := &Dialog{Message: msg, Language: lang, Position: f.root, State: state, owner: owner.Recipient()}
+	f.setDialog(to.Recipient(), d)
+	f.watch(d, f.root)
 	return nil
 }
 
 /*
 	Sends an instance of a menu to a user starting at a specified node
-	Tries to delete the old menu before sending a new one
+	An empty locale is detected from the user's language
+	Deletes the oldest menus of the user when the limit of open menus is reached,
+	the latest of them passes its history to the new menu
 */
 func (f *Menu) StartAt(to tb.Recipient, text, lang string, at *Node) error {
-	d, ok := f.GetDialog(to.Recipient())
-	if ok {
-		f.bot.Delete(d.Message)
-	} else {
+	return f.StartAtFor(to, to, text, lang, at)
+}
+
+/*
+	Sends an instance of a menu to a chat on behalf of the user who opened it starting at a specified node
+*/
+func (f *Menu) StartAtFor(to, owner tb.Recipient, text, lang string, at *Node) error {
+	lang = f.language(owner, lang)
+	d := f.evict(to)
+	if d == nil {
 		d = &Dialog{}
 	}
-	msg, err := f.bot.Send(to, text, at.markups[lang], tb.Silent)
+	// the evicted dialog may still be refreshing
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	msg, err := f.send(to, owner, at, lang, text)
 	if err != nil {
 		return err
 	}
+	f.remember(d, d.Position, at)
 	d.Message = msg
 	d.Language = lang
 	d.Position = at
+	d.owner = owner.Recipient()
 	f.setDialog(to.Recipient(), d)
+	f.watch(d, at)
 	return nil
 }
 
 /*
-	Takes a user to a specified menu position (page)
+	Sends an instance of a menu to a user starting at a node with a specified path
+*/
+func (f *Menu) StartAtPath(to tb.Recipient, text, lang, path string) error {
+	at, ok := f.Find(path)
+	if !ok {
+		return ErrNodeNotFound
+	}
+	return f.StartAt(to, text, lang, at)
+}
+
+/*
+	Takes a user to a specified menu position (page) in the menu the user is interacting with
+	An empty locale keeps the language of the dialog
 */
 func (f *Menu) MoveTo(to tb.Recipient, text, lang string, position *Node) error {
-	d, ok := f.GetDialog(to.Recipient())
+	d, ok := f.GetActiveDialog(to.Recipient())
 	if !ok {
 		return errors.New("dialog not found")
 	}
-	msg, err := f.bot.Edit(d.Message, text, position.markups[lang], tb.Silent)
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	if lang == "" {
+		lang = d.Language
+	}
+	msg, err := f.edit(to, d.Message, position, lang, text)
 	if err != nil {
 		return err
 	}
+	f.remember(d, d.Position, position)
 	d.Message = msg
 	d.Language = lang
 	d.Position = position
-	f.setDialog(to.Recipient(), d)
+	// a group menu stays in the chat it was posted in
+	f.setDialog(d.user, d)
+	f.watch(d, position)
 	return nil
 }
 
 /*
-	Removes the menu from a user and deletes the session
+	Removes all menus from a user and deletes the session
+	In the reply keyboard mode the text is sent along with the keyboard removal
 */
 func (f *Menu) Stop(to tb.Recipient, text, lang string) error {
-	if d, ok := f.GetDialog(to.Recipient()); ok {
-		f.bot.Delete(d.Message)
+	for _, d := range f.GetDialogs(to.Recipient()) {
+		d.mx.Lock()
+		if isInline(d.Message) {
+			// inline messages cannot be deleted by the bot, only their buttons
+			f.bot.EditReplyMarkup(d.Message, nil)
+		} else {
+			f.bot.Delete(d.Message)
+		}
+		f.removeDialog(d)
+		d.mx.Unlock()
+	}
+	if f.replyMode && text != "" {
+		_, err := f.bot.Send(to, text, &tb.ReplyMarkup{ReplyKeyboardRemove: true}, tb.Silent)
+		return err
 	}
-	f.deleteDialog(to.Recipient())
 	return nil
 }
+
+/*
+	Sets a locale path of the notification shown when a button of an outdated menu message is pressed
+*/
+func (f *Menu) SetStaleText(path string) *Menu {
+	f.stalePath = path
+	return f
+}
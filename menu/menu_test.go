@@ -0,0 +1,339 @@
+package menu
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tucnak/tr"
+	tb "gopkg.in/tucnak/telebot.v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+/*
+	A call of the Bot API received by the fake server
+*/
+type apiCall struct {
+	method string
+	params map[string]string
+}
+
+/*
+	A fake Bot API that answers every request with a plausible result
+	Methods listed in fail are answered with an error description
+*/
+type fakeAPI struct {
+	mx     sync.Mutex
+	calls  []apiCall
+	serial int
+	fail   map[string]string
+}
+
+func (a *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	raw := make(map[string]interface{})
+	json.NewDecoder(r.Body).Decode(&raw)
+	params := make(map[string]string, len(raw))
+	for key, value := range raw {
+		params[key] = fmt.Sprint(value)
+	}
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	a.calls = append(a.calls, apiCall{method: method, params: params})
+	if description, ok := a.fail[method]; ok {
+		fmt.Fprintf(w, `{"ok":false,"error_code":400,"description":%q}`, description)
+		return
+	}
+	var id int
+	switch {
+	case strings.HasPrefix(method, "send"):
+		a.serial++
+		id = a.serial
+	case strings.HasPrefix(method, "editMessage") && params["inline_message_id"] == "":
+		id, _ = strconv.Atoi(params["message_id"])
+	default:
+		fmt.Fprint(w, `{"ok":true,"result":true}`)
+		return
+	}
+	chat, _ := strconv.ParseInt(params["chat_id"], 10, 64)
+	msg := map[string]interface{}{
+		"message_id": id,
+		"chat":       map[string]interface{}{"id": chat, "type": "private"},
+		"text":       params["text"],
+	}
+	if photo, ok := params["photo"]; ok {
+		msg["photo"] = []map[string]interface{}{{"file_id": photo, "width": 1, "height": 1}}
+	}
+	result, _ := json.Marshal(msg)
+	fmt.Fprintf(w, `{"ok":true,"result":%s}`, result)
+}
+
+/*
+	Gets the methods called so far in order
+*/
+func (a *fakeAPI) methods() []string {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	methods := make([]string, len(a.calls))
+	for i, c := range a.calls {
+		methods[i] = c.method
+	}
+	return methods
+}
+
+/*
+	Gets the latest call of a method
+*/
+func (a *fakeAPI) last(method string) (apiCall, bool) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	for i := len(a.calls) - 1; i >= 0; i-- {
+		if a.calls[i].method == method {
+			return a.calls[i], true
+		}
+	}
+	return apiCall{}, false
+}
+
+/*
+	Forgets the calls made so far
+*/
+func (a *fakeAPI) reset() {
+	a.mx.Lock()
+	a.calls = nil
+	a.mx.Unlock()
+}
+
+/*
+	Creates a locale engine with English texts, paths are relative to the "en" directory
+*/
+func newTestEngine(t *testing.T, texts map[string]string) *tr.Engine {
+	dir := t.TempDir()
+	for path, text := range texts {
+		file := filepath.Join(dir, "en", filepath.FromSlash(path)+".txt")
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	engine, err := tr.NewEngine(dir, "en", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+/*
+	Creates a menu "flow" talking to a fake Bot API
+*/
+func newTestMenu(t *testing.T, texts map[string]string) (*Menu, *fakeAPI) {
+	api := &fakeAPI{fail: make(map[string]string)}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	bot, err := tb.NewBot(tb.Settings{URL: server.URL, Token: "test", Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewMenuFlow("flow", bot, newTestEngine(t, texts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f.SetDefaultLocale("en"), api
+}
+
+var callbackSerial int32
+
+/*
+	Creates a callback of a button pressed on a menu message
+*/
+func newCallback(from *tb.User, msg *tb.Message, data string) *tb.Callback {
+	serial := atomic.AddInt32(&callbackSerial, 1)
+	var pressed *tb.Message
+	if msg != nil {
+		pressed = &tb.Message{ID: msg.ID, Chat: msg.Chat, InlineID: msg.InlineID}
+	}
+	return &tb.Callback{ID: strconv.Itoa(int(serial)), Sender: from, Message: pressed, Data: data}
+}
+
+/*
+	Starts the menu for a user and returns the dialog
+*/
+func startDialog(t *testing.T, f *Menu, to *tb.User) *Dialog {
+	if err := f.Start(to, "Hello", "en"); err != nil {
+		t.Fatal(err)
+	}
+	d, ok := f.GetDialog(to.Recipient())
+	if !ok {
+		t.Fatal("no dialog after start")
+	}
+	return d
+}
+
+func TestSplitData(t *testing.T) {
+	tests := []struct {
+		data string
+		id   string
+		arg  string
+	}{
+		{"12", "12", ""},
+		{"12:next", "12", "next"},
+		{"12:2024-01-31:x", "12", "2024-01-31:x"},
+		{"12:", "12", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		id, arg := splitData(tt.data)
+		if id != tt.id || arg != tt.arg {
+			t.Errorf("splitData(%q) = %q, %q; want %q, %q", tt.data, id, arg, tt.id, tt.arg)
+		}
+	}
+}
+
+func TestHandleCallback(t *testing.T) {
+	user := &tb.User{ID: 1, LanguageCode: "en"}
+	texts := map[string]string{
+		"flow/a":      "Alpha",
+		"flow/a/a1":   "One",
+		"flow/t":      "Toggle",
+		"flow/g":      "Guarded",
+		"flow/r":      "Rate",
+		"flow/stale":  "Outdated",
+		"flow/denied": "Denied",
+	}
+	tests := []struct {
+		name     string
+		press    func(f *Menu, d *Dialog) *tb.Callback
+		position string
+		answer   string
+		alert    bool
+		check    func(t *testing.T, f *Menu, d *Dialog)
+	}{
+		{
+			name: "endpoint moves forward",
+			press: func(f *Menu, d *Dialog) *tb.Callback {
+				a, _ := f.Find("a")
+				return newCallback(user, d.Message, a.id)
+			},
+			position: "flow/a",
+		},
+		{
+			name: "removed node is only answered",
+			press: func(f *Menu, d *Dialog) *tb.Callback {
+				return newCallback(user, d.Message, "999")
+			},
+			position: "flow",
+		},
+		{
+			name: "toggle flips the state and stays",
+			press: func(f *Menu, d *Dialog) *tb.Callback {
+				toggle, _ := f.Find("t")
+				return newCallback(user, d.Message, toggle.id)
+			},
+			position: "flow",
+			check: func(t *testing.T, f *Menu, d *Dialog) {
+				toggle, _ := f.Find("t")
+				if !toggle.IsChecked(user) {
+					t.Error("the toggle is not checked")
+				}
+			},
+		},
+		{
+			name: "guard rejects with an alert",
+			press: func(f *Menu, d *Dialog) *tb.Callback {
+				guarded, _ := f.Find("g")
+				return newCallback(user, d.Message, guarded.id)
+			},
+			position: "flow",
+			answer:   "Denied",
+			alert:    true,
+		},
+		{
+			name: "child of a guarded page is rejected",
+			press: func(f *Menu, d *Dialog) *tb.Callback {
+				child, _ := f.Find("g/g1")
+				return newCallback(user, d.Message, child.id)
+			},
+			position: "flow",
+			answer:   "Denied",
+			alert:    true,
+		},
+		{
+			name: "child of a hidden page is rejected",
+			press: func(f *Menu, d *Dialog) *tb.Callback {
+				child, _ := f.Find("h/h1")
+				return newCallback(user, d.Message, child.id)
+			},
+			position: "flow",
+			answer:   "Denied",
+			alert:    true,
+		},
+		{
+			name: "widget argument reaches the widget",
+			press: func(f *Menu, d *Dialog) *tb.Callback {
+				rating, _ := f.Find("r")
+				return newCallback(user, d.Message, rating.id+":s3")
+			},
+			position: "flow/r",
+			check: func(t *testing.T, f *Menu, d *Dialog) {
+				rating, _ := f.Find("r")
+				if stars, _ := rating.widget.(*Rating).selected(rating, user); stars != 3 {
+					t.Errorf("selected %d stars; want 3", stars)
+				}
+			},
+		},
+		{
+			name: "older message is stale",
+			press: func(f *Menu, d *Dialog) *tb.Callback {
+				a, _ := f.Find("a")
+				return newCallback(user, &tb.Message{ID: d.Message.ID + 100, Chat: d.Message.Chat}, a.id)
+			},
+			position: "flow",
+			answer:   "Outdated",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, api := newTestMenu(t, texts)
+			forward := func(e *Node, c *tb.Callback) int { return Forward }
+			f.GetRoot().
+				AddWith("a", forward, f.NewNode("a1", nil)).
+				AddToggle("t", nil).
+				AddManySub([]*Node{
+					f.NewNode("g", forward).SetGuard(func(e *Node, of tb.Recipient) bool { return false }, "").Add("g1", forward),
+					f.NewNode("h", forward).SetVisible(func(e *Node, of tb.Recipient) bool { return false }).Add("h1", forward),
+					f.NewRatingNode("r", &Rating{}),
+				}).
+				GetFlow().Build("en")
+			d := startDialog(t, f, user)
+			c := tt.press(f, d)
+			f.handleCallback(c)
+			if d.Position.path != tt.position {
+				t.Errorf("position %q; want %q", d.Position.path, tt.position)
+			}
+			answer, ok := api.last("answerCallbackQuery")
+			if !ok {
+				t.Fatal("the callback is not answered")
+			}
+			if answer.params["callback_query_id"] != c.ID {
+				t.Errorf("answered %q; want %q", answer.params["callback_query_id"], c.ID)
+			}
+			if answer.params["text"] != tt.answer {
+				t.Errorf("answer %q; want %q", answer.params["text"], tt.answer)
+			}
+			if alert := answer.params["show_alert"] == "true"; alert != tt.alert {
+				t.Errorf("alert %v; want %v", alert, tt.alert)
+			}
+			if tt.check != nil {
+				tt.check(t, f, d)
+			}
+		})
+	}
+}
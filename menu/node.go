@@ -5,8 +5,8 @@ import (
 	tb "gopkg.in/tucnak/telebot.v2"
 	"log"
 	"strconv"
+	"strings"
 	"sync/atomic"
-	"time"
 )
 
 /*
@@ -19,6 +19,23 @@ const (
 	Stay         = 0
 	Forward      = 1
 	Back         = -1
+	Home         = 2
+)
+
+const (
+	kindButton = iota
+	kindToggle
+	kindCheckbox
+	kindRadio
+	kindURL
+	kindSwitchInline
+	kindLogin
+	kindConfirm
+	kindConfirmYes
+	kindConfirmNo
+	kindInput
+	kindWidget
+	kindItem
 )
 
 /*
@@ -35,6 +52,22 @@ type Node struct {
 	prev       *Node
 	nodes      []*Node
 	mustUpdate bool
+	kind       int
+	group      string
+	onChange   ChangeHook
+	link       tb.InlineButton
+	rows       []*Node
+	media      *Media
+	visible    Predicate
+	guard      Predicate
+	deniedPath string
+	labelData  LabelData
+	question   string
+	invalid    string
+	onInput    InputHandler
+	returnTo   *Node
+	widget     widget
+	live       *live
 }
 
 /*
@@ -51,6 +84,7 @@ func newNode(root *Menu, text string, endpoint Callback, prev *Node) *Node {
 		prev:       prev,
 		markups:    make(map[string]*tb.ReplyMarkup),
 		mustUpdate: false,
+		kind:       kindButton,
 	}
 }
 
@@ -104,11 +138,13 @@ func (e *Node) GetNodes() []*Node {
 }
 
 /*
-	Get a markups in a specified language
+	Get a copy of markups in a specified language
 	Caution! Menu must be built for the specified language beforehand
 */
 func (e *Node) GetMarkup(lang string) *tb.ReplyMarkup {
-	return e.markups[lang]
+	e.flow.treeMx.RLock()
+	defer e.flow.treeMx.RUnlock()
+	return cloneMarkup(e.markups[lang])
 }
 
 /*
@@ -136,6 +172,8 @@ func (e *Node) AddWith(text string, endpoint Callback, elements ...*Node) *Node
 */
 func (e *Node) AddSub(text string, endpoint Callback) *Node {
 	newElement := newNode(e.flow, text, endpoint, e)
+	e.flow.treeMx.Lock()
+	defer e.flow.treeMx.Unlock()
 	if e.nodes == nil {
 		e.nodes = make([]*Node, 1)
 		e.nodes[0] = newElement
@@ -150,6 +188,8 @@ func (e *Node) AddSub(text string, endpoint Callback) *Node {
 	Returns the current node
 */
 func (e *Node) AddManySub(elements []*Node) *Node {
+	e.flow.treeMx.Lock()
+	defer e.flow.treeMx.Unlock()
 	if e.nodes == nil {
 		e.nodes = make([]*Node, len(elements))
 		for i, el := range elements {
@@ -158,17 +198,80 @@ func (e *Node) AddManySub(elements []*Node) *Node {
 		}
 		return e
 	}
+	for _, el := range elements {
+		el.prev = e
+	}
 	e.nodes = append(e.nodes, elements...)
 	return e
 }
 
+/*
+	Removes a sub node
+	The menu must be rebuilt for the change to appear
+	Returns the current node
+*/
+func (e *Node) Remove(child *Node) *Node {
+	e.flow.treeMx.Lock()
+	defer e.flow.treeMx.Unlock()
+	for i, el := range e.nodes {
+		if el == child {
+			nodes := make([]*Node, 0, len(e.nodes)-1)
+			nodes = append(nodes, e.nodes[:i]...)
+			e.nodes = append(nodes, e.nodes[i+1:]...)
+			break
+		}
+	}
+	return e
+}
+
+/*
+	Finds a node by a locale path relative to the current node, e.g. "order/pizza"
+*/
+func (e *Node) Find(path string) (*Node, bool) {
+	temp := e
+	for _, text := range strings.Split(strings.Trim(path, "/"), "/") {
+		if text == "" {
+			continue
+		}
+		var found *Node
+		for _, child := range temp.nodes {
+			if child.text == text {
+				found = child
+				break
+			}
+		}
+		if found == nil {
+			return nil, false
+		}
+		temp = found
+	}
+	return temp, true
+}
+
+/*
+	Tries to find a node with ID down the tree
+*/
+func (e *Node) Search(nodeId string) (*Node, bool) {
+	for _, child := range e.nodes {
+		if child.id == nodeId {
+			return child, true
+		}
+		if found, ok := child.Search(nodeId); ok {
+			return found, true
+		}
+	}
+	return nil, false
+}
+
 /*
 	Sets a new caption for the flow
 	that will be updated in the next menu iteration
 	params are automatically placed in the text if provided
 */
 func (e *Node) SetCaption(c *tb.Callback, text string, params ...interface{}) *Node {
-	if d, ok := e.flow.GetDialog(c.Sender.Recipient()); ok {
+	if d, ok := e.flow.dialogOf(c); ok {
+		d.mx.Lock()
+		defer d.mx.Unlock()
 		if len(params) > 0 {
 			text = fmt.Sprintf(text, params...)
 		}
@@ -182,19 +285,24 @@ func (e *Node) SetCaption(c *tb.Callback, text string, params ...interface{}) *N
 
 /*
 	Gets a language currently used in a dialog by the user
+	Without a dialog the language is detected from the user
 */
 func (e *Node) GetLanguage(c *tb.Callback) string {
-	if d, ok := e.flow.GetDialog(c.Sender.Recipient()); ok {
+	if d, ok := e.flow.dialogOf(c); ok {
 		return d.Language
 	}
-	return e.flow.defaultLocale
+	return e.flow.DetectLanguage(c.Sender)
 }
 
 /*
 	Sets a language for the user's dialog
+	The choice is remembered and preferred over the detected language
 */
 func (e *Node) SetLanguage(c *tb.Callback, lang string) *Node {
-	if d, ok := e.flow.GetDialog(c.Sender.Recipient()); ok {
+	e.flow.SetUserLanguage(c.Sender, lang)
+	if d, ok := e.flow.dialogOf(c); ok {
+		d.mx.Lock()
+		defer d.mx.Unlock()
 		d.Language = lang
 		e.mustUpdate = true
 		e.next(c)
@@ -203,43 +311,82 @@ func (e *Node) SetLanguage(c *tb.Callback, lang string) *Node {
 }
 
 /*
-	Updates the menu
+	Updates the menu and displays the specified page
 */
-func (e *Node) update(recipient tb.Recipient, d *Dialog, markup *tb.ReplyMarkup) {
-	newMsg, err := e.flow.bot.Edit(d.Message, d.Message.Text, markup)
+func (e *Node) update(recipient tb.Recipient, d *Dialog, page *Node) bool {
+	if d.Position != nil && (d.Position.IsWidget() || d.Position.IsLive()) && d.Position != page {
+		// leaving a page that displays its own caption
+		if d.question != "" && d.Message.Text == d.question {
+			d.Message.Text = d.caption
+		}
+		d.caption, d.question = "", ""
+	}
+	e.flow.pageCaption(d, page, recipient)
+	newMsg, err := e.flow.edit(recipient, d.Message, page, d.Language, d.Message.Text)
 	if err != nil {
 		log.Println("failed to continue", recipient.Recipient(), err)
-		return
+		return false
 	}
 	e.mustUpdate = false
 	d.Message = newMsg
-	d.Position = e
+	d.Position = page
+	e.flow.reindex(d)
+	e.flow.watch(d, page)
+	if d.input != nil && d.input != page {
+		// leaving an input page stops waiting for the reply
+		d.input = nil
+	}
+	return true
 }
 
 /*
-	Goes back to the previous menu
+	Goes back to the page the user came from
+	Falls back to the parent page when the history is empty
 */
 func (e *Node) back(c *tb.Callback) *Node {
-	d, ok := e.flow.GetDialog(c.Sender.Recipient())
+	d, ok := e.flow.dialogOf(c)
 	if !ok {
 		log.Println(c.Sender.ID, "does not exist")
 		return nil
 	}
-	if e.prev == nil || e.prev.prev == nil {
+	target := d.Previous()
+	if target == nil && d.Position != nil {
+		target = d.Position.prev
+	}
+	if target == nil {
 		if e.mustUpdate {
-			e.update(c.Sender, d, e.flow.root.markups[d.Language])
-			return e
+			e.update(c.Sender, d, e.flow.root)
+			return e.flow.root
 		}
 		return nil
 	}
-	newMsg, err := e.flow.bot.Edit(d.Message, d.Message.Text, e.prev.prev.markups[d.Language])
-	if err != nil {
-		log.Println("failed to back", c.Sender.ID, err)
+	if !e.update(c.Sender, d, target) {
 		return nil
 	}
-	d.Message = newMsg
-	d.Position = e.prev
-	return e.prev
+	if len(d.History) > 0 {
+		d.History = d.History[:len(d.History)-1]
+	}
+	return target
+}
+
+/*
+	Goes to the root page and clears the history
+*/
+func (e *Node) home(c *tb.Callback) *Node {
+	d, ok := e.flow.dialogOf(c)
+	if !ok {
+		log.Println(c.Sender.ID, "does not exist")
+		return nil
+	}
+	if d.Position == e.flow.root && !e.mustUpdate {
+		d.History = nil
+		return nil
+	}
+	if !e.update(c.Sender, d, e.flow.root) {
+		return nil
+	}
+	d.History = nil
+	return e.flow.root
 }
 
 /*
@@ -250,16 +397,24 @@ func (e *Node) next(c *tb.Callback) {
 	if nodes < 1 && !e.mustUpdate {
 		return
 	}
-	d, ok := e.flow.GetDialog(c.Sender.Recipient())
+	d, ok := e.flow.dialogOf(c)
 	if !ok {
 		log.Println(c.Sender.ID, "does not exist")
 		return
 	}
-	markup := e.markups
 	if nodes < 1 {
-		markup = e.prev.markups
+		// a leaf only refreshes the page it was pressed on
+		page := d.Position
+		if page == nil {
+			page = e.prev
+		}
+		e.update(c.Sender, d, page)
+		return
+	}
+	from := d.Position
+	if e.update(c.Sender, d, e) {
+		e.flow.remember(d, from, e)
 	}
-	e.update(c.Sender, d, markup[d.Language])
 }
 
 /*
@@ -271,40 +426,86 @@ func (e *Node) build(basePath, lang string) {
 	} else {
 		e.path = basePath
 	}
+	for _, child := range e.nodes {
+		child.build(e.path, lang)
+	}
+	if e.flow.replyMode {
+		e.buildReply(lang)
+		return
+	}
 	buttons := make([][]tb.InlineButton, len(e.nodes))
+	e.rows = make([]*Node, len(e.nodes))
 	for i, child := range e.nodes {
-		child.build(e.path, lang)
+		e.rows[i] = child
+		text := e.flow.tr(lang, child.labelPath(child.path))
+		if child.IsLink() {
+			// link buttons are handled by Telegram clients
+			buttons[i] = []tb.InlineButton{child.linkButton(text)}
+			continue
+		}
 		buttons[i] = []tb.InlineButton{
 			{
-				Unique: strconv.FormatInt(time.Now().Unix(), 10) + uniquePrefix + lang + child.id,
-				Text:   e.flow.engine.Lang(lang).Tr(child.path),
+				Unique: e.flow.unique(),
+				Text:   text,
+				Data:   child.id,
 			},
 		}
-		if child.endpoint != nil {
-			e.flow.bot.Handle(&buttons[i][0], child.handle)
-		} else {
-			e.flow.bot.Handle(&buttons[i][0], child.handleDeadEnd)
-		}
 	}
 	e.markups[lang] = &tb.ReplyMarkup{
 		InlineKeyboard: buttons,
 	}
 }
 
+/*
+	Passes a callback to a handler that suits the node kind
+	Clicks of users that are not allowed to press the node or any page above it are rejected
+*/
+func (e *Node) dispatch(c *tb.Callback) {
+	if b := e.blocked(c.Sender, nil); b != nil {
+		b.deny(c)
+	} else if e.kind == kindConfirm {
+		e.handleConfirm(c)
+	} else if e.kind == kindConfirmYes {
+		e.prev.accept(c)
+	} else if e.kind == kindConfirmNo {
+		e.prev.cancel(c)
+	} else if e.kind == kindInput {
+		e.handleInput(c)
+	} else if e.kind == kindWidget {
+		e.handleWidget(c)
+	} else if e.kind == kindItem {
+		e.widget.press(e, c, "+")
+	} else if e.IsStateful() {
+		e.handleState(c)
+	} else if e.endpoint != nil {
+		e.handle(c)
+	} else {
+		e.handleDeadEnd(c)
+	}
+}
+
 /*
 	Default handler for pagination
+	The callback is answered after the endpoint returns
 */
 func (e *Node) handle(c *tb.Callback) {
-	err := e.flow.bot.Respond(c)
-	if err != nil {
-		log.Println("failed to respond", c.Sender.ID, err)
-		return
-	}
-	result := e.endpoint(e, c)
-	if result == Forward {
+	result := Stay
+	e.flow.release(c, func() { result = e.endpoint(e, c) })
+	e.flow.respond(c)
+	e.navigate(c, result)
+}
+
+/*
+	Takes the user further according to the result of an endpoint
+*/
+func (e *Node) navigate(c *tb.Callback, result int) {
+	switch result {
+	case Forward:
 		e.next(c)
-	} else if result == Back {
+	case Back:
 		e.back(c)
+	case Home:
+		e.home(c)
 	}
 }
 
@@ -312,10 +513,6 @@ func (e *Node) handle(c *tb.Callback) {
 	Handler for menu buttons with no provided endpoint (callback)
 */
 func (e *Node) handleDeadEnd(c *tb.Callback) {
-	err := e.flow.bot.Respond(c)
-	if err != nil {
-		log.Println("failed to respond", c.Sender.ID, err)
-		return
-	}
+	e.flow.respond(c)
 	e.next(c)
 }
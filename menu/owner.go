@@ -0,0 +1,98 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+/*
+	The default locale path (relative to the menu id) of the alert shown
+	when a user presses a button of a menu that belongs to someone else
+*/
+const DefaultForeignText = "foreign"
+
+/*
+	Binds every menu message to the user who opened it, e.g. for menus posted in group chats
+	Clicks of other users are rejected with an alert unless they are operators of the dialog
+	Menus posted in groups must be started with StartFor to tell the owner from the chat,
+	inline messages are bound to the user who has chosen the inline result
+	and inline messages the menu doesn't keep track of reject every click
+*/
+func (f *Menu) SetOwnerOnly(enabled bool) *Menu {
+	f.ownerOnly = enabled
+	return f
+}
+
+/*
+	Checks if menu messages are bound to the users who opened them
+*/
+func (f *Menu) IsOwnerOnly() bool {
+	return f.ownerOnly
+}
+
+/*
+	Sets a locale path of the alert shown when a user presses a button of someone else's menu
+*/
+func (f *Menu) SetForeignText(path string) *Menu {
+	f.foreignPath = path
+	return f
+}
+
+/*
+	Allows a user to operate the menu along with its owner
+*/
+func (f *Menu) AddOperator(d *Dialog, of tb.Recipient) *Menu {
+	f.mx.Lock()
+	if d.operators == nil {
+		d.operators = make(map[string]bool)
+	}
+	d.operators[of.Recipient()] = true
+	f.mx.Unlock()
+	return f
+}
+
+/*
+	Revokes the permission of a user to operate the menu
+*/
+func (f *Menu) RemoveOperator(d *Dialog, of tb.Recipient) *Menu {
+	f.mx.Lock()
+	delete(d.operators, of.Recipient())
+	f.mx.Unlock()
+	return f
+}
+
+/*
+	Checks if a user is the owner or an operator of the menu
+*/
+func (f *Menu) CanOperate(d *Dialog, of tb.Recipient) bool {
+	f.mx.RLock()
+	defer f.mx.RUnlock()
+	return d.owner != "" && d.owner == of.Recipient() || d.operators[of.Recipient()]
+}
+
+/*
+	Gets an id of the user who opened the menu
+	The owner of an inline message the menu has lost track of, e.g. after a restart, is unknown
+*/
+func (d *Dialog) GetOwner() (string, bool) {
+	return d.owner, d.owner != ""
+}
+
+/*
+	Rejects clicks on a menu of another user when the menu is owner-only
+	The alert is shown in the language of the user who clicked
+	Only internal use is intended
+*/
+func (f *Menu) rejectForeign(c *tb.Callback) bool {
+	if !f.ownerOnly || c.Message == nil {
+		return false
+	}
+	d, ok := f.GetDialogByMessage(c.Message)
+	if ok && f.CanOperate(d, c.Sender) || !ok && !isInline(c.Message) {
+		return false
+	}
+	resp := f.response(c)
+	resp.Text = f.tr(f.DetectLanguage(c.Sender), f.foreignPath)
+	resp.ShowAlert = true
+	f.respond(c)
+	return true
+}
@@ -0,0 +1,165 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"testing"
+)
+
+func TestOwnerOnly(t *testing.T) {
+	owner := &tb.User{ID: 1}
+	operator := &tb.User{ID: 2}
+	stranger := &tb.User{ID: 3}
+	group := &tb.Chat{ID: -100, Type: tb.ChatGroup}
+	tests := []struct {
+		name      string
+		ownerOnly bool
+		open      func(t *testing.T, f *Menu) *tb.Message
+		from      *tb.User
+		rejected  bool
+	}{
+		{
+			name:      "owner of a group menu",
+			ownerOnly: true,
+			open:      startInGroup,
+			from:      owner,
+		},
+		{
+			name:      "stranger in a group",
+			ownerOnly: true,
+			open:      startInGroup,
+			from:      stranger,
+			rejected:  true,
+		},
+		{
+			name:      "operator in a group",
+			ownerOnly: true,
+			open:      startInGroup,
+			from:      operator,
+		},
+		{
+			name:      "stranger in a group without owner-only",
+			ownerOnly: false,
+			open:      startInGroup,
+			from:      stranger,
+		},
+		{
+			name:      "owner of a menu started by /start in a group",
+			ownerOnly: true,
+			open: func(t *testing.T, f *Menu) *tb.Message {
+				f.HandleStart("Hello", "en")(&tb.Message{ID: 1, Chat: group, Sender: owner, Text: "/start"})
+				d, ok := f.GetDialog(group.Recipient())
+				if !ok {
+					t.Fatal("no menu in the group")
+				}
+				return d.Message
+			},
+			from: owner,
+		},
+		{
+			name:      "stranger on an attached inline message",
+			ownerOnly: true,
+			open:      attachInline,
+			from:      stranger,
+			rejected:  true,
+		},
+		{
+			name:      "owner of an attached inline message",
+			ownerOnly: true,
+			open:      attachInline,
+			from:      owner,
+		},
+		{
+			name:      "unknown inline message",
+			ownerOnly: true,
+			open: func(t *testing.T, f *Menu) *tb.Message {
+				return &tb.Message{InlineID: "unknown"}
+			},
+			from:     owner,
+			rejected: true,
+		},
+		{
+			name:      "unknown inline message without owner-only",
+			ownerOnly: false,
+			open: func(t *testing.T, f *Menu) *tb.Message {
+				return &tb.Message{InlineID: "unknown"}
+			},
+			from: stranger,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, api := newTestMenu(t, map[string]string{"flow/a": "Alpha", "flow/foreign": "Not yours"})
+			f.SetOwnerOnly(tt.ownerOnly).GetRoot().AddWith("a", nil, f.NewBackNode("back")).GetFlow().Build("en")
+			msg := tt.open(t, f)
+			if d, ok := f.GetDialogByMessage(msg); ok {
+				f.AddOperator(d, operator)
+			}
+			a, _ := f.Find("a")
+			f.handleCallback(newCallback(tt.from, msg, a.id))
+			answer, _ := api.last("answerCallbackQuery")
+			if rejected := answer.params["show_alert"] == "true"; rejected != tt.rejected {
+				t.Errorf("rejected %v; want %v", rejected, tt.rejected)
+			}
+			if tt.rejected && answer.params["text"] != "Not yours" {
+				t.Errorf("alert %q; want %q", answer.params["text"], "Not yours")
+			}
+			d, ok := f.GetDialogByMessage(msg)
+			if moved := ok && d.Position == a; moved == tt.rejected {
+				t.Errorf("moved to the page %v; want %v", moved, !tt.rejected)
+			}
+			if ok && d.owner == tt.from.Recipient() && tt.from == stranger {
+				t.Error("the stranger has become the owner")
+			}
+		})
+	}
+}
+
+func startInGroup(t *testing.T, f *Menu) *tb.Message {
+	group := &tb.Chat{ID: -100, Type: tb.ChatGroup}
+	if err := f.StartFor(group, &tb.User{ID: 1}, "Hello", "en"); err != nil {
+		t.Fatal(err)
+	}
+	d, _ := f.GetDialog(group.Recipient())
+	return d.Message
+}
+
+func attachInline(t *testing.T, f *Menu) *tb.Message {
+	if err := f.Attach(&tb.User{ID: 1}, "shared", "Hello", "en"); err != nil {
+		t.Fatal(err)
+	}
+	return &tb.Message{InlineID: "shared"}
+}
+
+func TestGroupMoveTo(t *testing.T) {
+	owner := &tb.User{ID: 1}
+	member := &tb.User{ID: 2}
+	group := &tb.Chat{ID: -100, Type: tb.ChatGroup}
+	for _, from := range []*tb.User{owner, member} {
+		f, _ := newTestMenu(t, map[string]string{"flow/move": "Move", "flow/target": "Target"})
+		var target *Node
+		f.GetRoot().
+			Add("move", func(e *Node, c *tb.Callback) int {
+				if err := f.MoveTo(c.Sender, "Moved", "", target); err != nil {
+					t.Errorf("user %d: %v", c.Sender.ID, err)
+				}
+				return Stay
+			}).
+			Add("target", nil).
+			GetFlow().Build("en")
+		target, _ = f.Find("target")
+		move, _ := f.Find("move")
+		msg := startInGroup(t, f)
+
+		f.handleCallback(newCallback(from, msg, move.id))
+		d, ok := f.GetDialog(group.Recipient())
+		if !ok {
+			t.Fatalf("user %d: the menu has left the group", from.ID)
+		}
+		if d.Position != target {
+			t.Errorf("user %d: the menu is at %q; want %q", from.ID, d.Position.path, target.path)
+		}
+		if dialogs := f.GetDialogs(from.Recipient()); len(dialogs) > 0 {
+			t.Errorf("user %d: %d private menus; want none", from.ID, len(dialogs))
+		}
+	}
+}
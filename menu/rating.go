@@ -0,0 +1,183 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"log"
+	"strconv"
+	"strings"
+)
+
+/*
+	Handler of a rating given by the user, c.Sender is the user
+	The comment is empty when the user skipped it or it wasn't asked
+	Returns the same navigation results as Callback, e.g. Back or Home
+*/
+type RatingHandler func(e *Node, c *tb.Callback, rating int, comment string) int
+
+/*
+	A row of stars that shows the current selection and an OK button
+	Stars is the highest rating, zero means 5
+	Comment asks the user for an optional comment after rating,
+	Prompt is a locale path of the caption displayed while waiting for the comment
+*/
+type Rating struct {
+	Stars   int
+	Comment bool
+	Prompt  string
+	Handler RatingHandler
+}
+
+/*
+	The default highest rating
+*/
+const DefaultStars = 5
+
+/*
+	The default locale path (relative to the menu id) of the directory with texts of ratings,
+	"rating/skip" is the label of the button that skips the comment
+	Missing texts are displayed in English
+*/
+const DefaultRatingText = "rating"
+
+const commentMark = "c"
+
+/*
+	Sets a locale path of the directory with texts of ratings
+*/
+func (f *Menu) SetRatingTexts(path string) *Menu {
+	f.ratingPath = path
+	return f
+}
+
+/*
+	Creates a new rating node in the flow
+	that opens a row of stars and passes the rating to the handler of the rating
+	Comments arrive through Menu.Process
+*/
+func (f *Menu) NewRatingNode(text string, rating *Rating) *Node {
+	return newWidgetNode(f, text, rating, f.root)
+}
+
+/*
+	Adds a new rating node to the current node
+	Returns the current node
+*/
+func (e *Node) AddRating(text string, rating *Rating) *Node {
+	e.AddManySub([]*Node{newWidgetNode(e.flow, text, rating, e)})
+	return e
+}
+
+/*
+	Gets the highest rating
+*/
+func (r *Rating) stars() int {
+	if r.Stars < 1 {
+		return DefaultStars
+	}
+	return r.Stars
+}
+
+/*
+	Gets the rating selected by the user and whether the comment is being asked
+*/
+func (r *Rating) selected(e *Node, of tb.Recipient) (int, bool) {
+	value, _ := e.flow.getState(of.Recipient(), e.stateKey())
+	rating, _ := strconv.Atoi(strings.TrimSuffix(value, commentMark))
+	return rating, strings.HasSuffix(value, commentMark)
+}
+
+/*
+	Starts with no stars selected
+*/
+func (r *Rating) open(e *Node, c *tb.Callback, d *Dialog) {
+	e.flow.setState(c.Sender.Recipient(), e.stateKey(), "")
+}
+
+/*
+	Renders the stars or the skip button while the comment is being asked
+*/
+func (r *Rating) keyboard(e *Node, of tb.Recipient, lang string) [][]tb.InlineButton {
+	rating, commenting := r.selected(e, of)
+	if commenting {
+		return [][]tb.InlineButton{{e.widgetButton(e.flow.trOr(lang, e.flow.ratingPath+"/skip", "Skip"), "skip")}}
+	}
+	stars := make([]tb.InlineButton, r.stars())
+	for i := range stars {
+		star := "☆"
+		if i < rating {
+			star = "★"
+		}
+		stars[i] = e.widgetButton(star, "s"+strconv.Itoa(i+1))
+	}
+	rows := [][]tb.InlineButton{stars}
+	if rating > 0 {
+		rows = append(rows, []tb.InlineButton{e.widgetButton(e.flow.tr(lang, e.flow.confirmPath), "ok")})
+	}
+	return rows
+}
+
+/*
+	Selects stars, asks for the comment and passes the rating to the handler
+*/
+func (r *Rating) press(e *Node, c *tb.Callback, arg string) {
+	rating, commenting := r.selected(e, c.Sender)
+	switch {
+	case strings.HasPrefix(arg, "s") && !commenting:
+		if selected, err := strconv.Atoi(arg[1:]); err == nil && selected > 0 && selected <= r.stars() {
+			rating = selected
+		}
+		e.flow.setState(c.Sender.Recipient(), e.stateKey(), strconv.Itoa(rating))
+	case arg == "ok" && rating > 0 && !commenting && r.Comment:
+		d, ok := e.flow.dialogOf(c)
+		if !ok {
+			log.Println(c.Sender.ID, "does not exist")
+			break
+		}
+		e.flow.setState(c.Sender.Recipient(), e.stateKey(), strconv.Itoa(rating)+commentMark)
+		if r.Prompt != "" {
+			d.caption = d.Message.Text
+			d.question = e.Translate(c, r.Prompt)
+			d.Message.Text = d.question
+		}
+		d.input = e
+	case arg == "ok" && rating > 0 && !commenting, arg == "skip" && commenting:
+		r.finish(e, c, rating, "")
+		return
+	}
+	e.flow.respond(c)
+	e.refresh(c)
+}
+
+/*
+	Passes the comment typed by the user to the handler
+*/
+func (r *Rating) receive(e *Node, c *tb.Callback, d *Dialog, m *tb.Message) {
+	rating, commenting := r.selected(e, m.Sender)
+	if !commenting {
+		d.input = nil
+		return
+	}
+	r.finish(e, c, rating, m.Text)
+}
+
+/*
+	Passes the rating to the handler and resets the stars
+*/
+func (r *Rating) finish(e *Node, c *tb.Callback, rating int, comment string) {
+	e.flow.setState(c.Sender.Recipient(), e.stateKey(), "")
+	if d, ok := e.flow.dialogOf(c); ok {
+		d.input = nil
+	}
+	result := Back
+	if r.Handler != nil {
+		e.flow.release(c, func() { result = r.Handler(e, c, rating, comment) })
+	}
+	e.flow.respond(c)
+	e.restore(c)
+	e.mustUpdate = true
+	if result == Stay {
+		e.refresh(c)
+		return
+	}
+	e.navigate(c, result)
+}
@@ -0,0 +1,490 @@
+package menu
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	tb "gopkg.in/tucnak/telebot.v2"
+	"log"
+	"strconv"
+	"strings"
+)
+
+/*
+	A field of a record displayed on the detail page
+	Name is a locale path, editable fields get an edit button
+*/
+type Field struct {
+	Name     string
+	Value    string
+	Editable bool
+}
+
+/*
+	A record of a data source, e.g. an address or a subscription of the user
+*/
+type Record struct {
+	ID     string
+	Title  string
+	Fields []Field
+}
+
+/*
+	A source of records of a user
+	List returns a page of records along with the total amount of records
+	Update receives the name of the field and a value typed by the user,
+	an error keeps the user editing the field
+	IDs too long for callback data of a button are hashed and looked up on the displayed list page,
+	ErrRecordNotFound is logged when the record is gone from there
+*/
+type DataSource interface {
+	List(of tb.Recipient, offset, limit int) ([]Record, int, error)
+	Get(of tb.Recipient, id string) (Record, error)
+	Delete(of tb.Recipient, id string) error
+	Update(of tb.Recipient, id, field, value string) error
+}
+
+/*
+	A data source that finds records by a query typed by the user on the list page
+	Without it the records are filtered by their titles
+*/
+type RecordSearcher interface {
+	Search(of tb.Recipient, query string, offset, limit int) ([]Record, int, error)
+}
+
+/*
+	A master-detail screen of records that lives in a single menu message
+	The list page opens a detail page of a record with edit and delete buttons,
+	deletion has to be confirmed
+	With SetTypeToSearch text typed on the list page filters the records, see RecordSearcher
+	PageSize is the amount of records on the list page, zero means 5
+*/
+type Records struct {
+	Source   DataSource
+	PageSize int
+}
+
+/*
+	The default locale path (relative to the menu id) of the directory with texts of record screens,
+	"records/edit", "records/delete", "records/list", "records/question",
+	"records/prompt", "records/invalid" and "records/error"
+	Missing texts are displayed in English
+*/
+const DefaultRecordsText = "records"
+
+/*
+	The default amount of records on a list page
+*/
+const DefaultPageSize = 5
+
+/*
+	The maximum size of callback data of a button in bytes set by Telegram
+*/
+const maxCallbackData = 64
+
+var ErrRecordNotFound = errors.New("record not found on the displayed page")
+
+const (
+	modeList    = "l"
+	modeItem    = "i"
+	modeConfirm = "c"
+	modeEdit    = "e"
+)
+
+/*
+	Sets a locale path of the directory with texts of record screens
+*/
+func (f *Menu) SetRecordsTexts(path string) *Menu {
+	f.recordsPath = path
+	return f
+}
+
+/*
+	Creates a new records node in the flow
+	that opens the list of records of the data source
+	Values of edited fields arrive through Menu.Process
+*/
+func (f *Menu) NewRecordsNode(text string, records *Records) *Node {
+	return newWidgetNode(f, text, records, f.root)
+}
+
+/*
+	Adds a new records node to the current node
+	Returns the current node
+*/
+func (e *Node) AddRecords(text string, records *Records) *Node {
+	e.AddManySub([]*Node{newWidgetNode(e.flow, text, records, e)})
+	return e
+}
+
+/*
+	A screen of the records widget: a mode, a list page, a record and a field
+*/
+type screen struct {
+	mode  string
+	page  int
+	id    string
+	field int
+}
+
+/*
+	Formats a screen to be kept in the user state
+*/
+func (s screen) String() string {
+	return s.mode + "|" + strconv.Itoa(s.page) + "|" + strconv.Itoa(s.field) + "|" + s.id
+}
+
+/*
+	Parses a screen kept in the user state, an invalid screen is the first list page
+*/
+func parseScreen(value string) screen {
+	parts := strings.SplitN(value, "|", 4)
+	if len(parts) < 4 {
+		return screen{mode: modeList}
+	}
+	page, _ := strconv.Atoi(parts[1])
+	field, _ := strconv.Atoi(parts[2])
+	if page < 0 || field < 0 {
+		return screen{mode: modeList}
+	}
+	return screen{mode: parts[0], page: page, id: parts[3], field: field}
+}
+
+/*
+	Gets the amount of records on a list page
+*/
+func (r *Records) pageSize() int {
+	if r.PageSize < 1 {
+		return DefaultPageSize
+	}
+	return r.PageSize
+}
+
+/*
+	Gets the screen displayed to the user
+*/
+func (r *Records) screen(e *Node, of tb.Recipient) screen {
+	value, _ := e.flow.getState(of.Recipient(), e.stateKey())
+	return parseScreen(value)
+}
+
+/*
+	Translates a text of record screens
+*/
+func (r *Records) text(e *Node, lang, name, text string) string {
+	return e.flow.trOr(lang, e.flow.recordsPath+"/"+name, text)
+}
+
+/*
+	Opens the first list page
+*/
+func (r *Records) open(e *Node, c *tb.Callback, d *Dialog) {
+	d.caption = d.Message.Text
+	d.question = ""
+	e.setQuery(c.Sender, "")
+	e.flow.setState(c.Sender.Recipient(), e.stateKey(), screen{mode: modeList}.String())
+}
+
+/*
+	Renders the screen displayed to the user
+*/
+func (r *Records) keyboard(e *Node, of tb.Recipient, lang string) [][]tb.InlineButton {
+	s := r.screen(e, of)
+	back := e.widgetButton(r.text(e, lang, "list", "« Back to the list"), "p"+strconv.Itoa(s.page))
+	switch s.mode {
+	case modeItem:
+		record, err := r.Source.Get(of, s.id)
+		if err != nil {
+			return [][]tb.InlineButton{{back}}
+		}
+		rows := make([][]tb.InlineButton, 0, len(record.Fields)+2)
+		for i, field := range record.Fields {
+			if field.Editable {
+				label := r.text(e, lang, "edit", "Edit") + ": " + e.flow.tr(lang, field.Name)
+				rows = append(rows, []tb.InlineButton{e.widgetButton(label, "e"+strconv.Itoa(i))})
+			}
+		}
+		return append(rows,
+			[]tb.InlineButton{e.widgetButton(r.text(e, lang, "delete", "Delete"), "x")},
+			[]tb.InlineButton{back},
+		)
+	case modeConfirm:
+		return [][]tb.InlineButton{{
+			e.widgetButton(e.flow.tr(lang, e.flow.confirmPath), "y"),
+			e.widgetButton(e.flow.tr(lang, e.flow.cancelPath), "o"+r.ref(e, s.id)),
+		}}
+	case modeEdit:
+		return [][]tb.InlineButton{{e.widgetButton(e.flow.tr(lang, e.flow.cancelPath), "o"+r.ref(e, s.id))}}
+	}
+	size := r.pageSize()
+	records, total, page, err := r.list(e, of, s.page)
+	if err != nil {
+		log.Println("failed to list records", of.Recipient(), err)
+		return nil
+	}
+	s.page = page
+	rows := make([][]tb.InlineButton, 0, len(records)+1)
+	for _, record := range records {
+		rows = append(rows, []tb.InlineButton{e.widgetButton(record.Title, "o"+r.ref(e, record.ID))})
+	}
+	if pages := (total + size - 1) / size; pages > 1 {
+		paging := []tb.InlineButton{
+			e.widgetButton(" ", noop),
+			e.widgetButton(strconv.Itoa(s.page+1)+"/"+strconv.Itoa(pages), noop),
+			e.widgetButton(" ", noop),
+		}
+		if s.page > 0 {
+			paging[0] = e.widgetButton("«", "p"+strconv.Itoa(s.page-1))
+		}
+		if s.page < pages-1 {
+			paging[2] = e.widgetButton("»", "p"+strconv.Itoa(s.page+1))
+		}
+		rows = append(rows, paging)
+	}
+	if row := e.queryRow(of, lang); row != nil {
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+/*
+	Gets a list page of records matching the query of the user along with the total amount of them
+	A page left empty by deleted records is replaced with the last page
+*/
+func (r *Records) list(e *Node, of tb.Recipient, page int) ([]Record, int, int, error) {
+	size := r.pageSize()
+	query := e.query(of)
+	records, total, err := r.fetch(of, query, page*size, size)
+	if err != nil || len(records) > 0 || page < 1 || total < 1 {
+		return records, total, page, err
+	}
+	page = (total - 1) / size
+	records, total, err = r.fetch(of, query, page*size, size)
+	return records, total, page, err
+}
+
+/*
+	Gets records matching a query from the data source
+	Sources that cannot search are read through and filtered by titles
+*/
+func (r *Records) fetch(of tb.Recipient, query string, offset, limit int) ([]Record, int, error) {
+	if query == "" {
+		return r.Source.List(of, offset, limit)
+	}
+	if s, ok := r.Source.(RecordSearcher); ok {
+		return s.Search(of, query, offset, limit)
+	}
+	size := r.pageSize()
+	matched := make([]Record, 0)
+	for from := 0; ; from += size {
+		records, total, err := r.Source.List(of, from, size)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, record := range records {
+			if matchQuery(record.Title, query) {
+				matched = append(matched, record)
+			}
+		}
+		if len(records) < 1 || from+len(records) >= total {
+			break
+		}
+	}
+	if offset >= len(matched) {
+		return nil, len(matched), nil
+	}
+	end := offset + limit
+	if end > len(matched) {
+		end = len(matched)
+	}
+	return matched[offset:end], len(matched), nil
+}
+
+/*
+	Gets a reference to a record that fits in callback data of a button
+	An ID that is too long is replaced with a hash resolved against the displayed records
+*/
+func (r *Records) ref(e *Node, id string) string {
+	// "\f<unique>|<node id>:o<id>"
+	size := len(e.flow.unique()) + len(e.id) + len(id) + 4
+	if size <= maxCallbackData && !strings.HasPrefix(id, "#") {
+		return id
+	}
+	sum := sha1.Sum([]byte(id))
+	return "#" + hex.EncodeToString(sum[:8])
+}
+
+/*
+	Finds the ID of a record by a reference from callback data
+	Hashes are looked up among the opened record and the records of the displayed list page
+*/
+func (r *Records) resolve(e *Node, of tb.Recipient, s screen, ref string) (string, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return ref, nil
+	}
+	if s.id != "" && r.ref(e, s.id) == ref {
+		return s.id, nil
+	}
+	records, _, _, err := r.list(e, of, s.page)
+	if err != nil {
+		return "", err
+	}
+	for _, record := range records {
+		if r.ref(e, record.ID) == ref {
+			return record.ID, nil
+		}
+	}
+	return "", errors.Wrap(ErrRecordNotFound, ref)
+}
+
+/*
+	Switches screens, deletes records and starts editing fields
+*/
+func (r *Records) press(e *Node, c *tb.Callback, arg string) {
+	d, ok := e.flow.dialogOf(c)
+	if !ok {
+		log.Println(c.Sender.ID, "does not exist")
+		e.flow.respond(c)
+		return
+	}
+	s := r.screen(e, c.Sender)
+	lang := d.Language
+	switch arg[0] {
+	case 'p':
+		// back to the list restores the caption
+		page, err := strconv.Atoi(arg[1:])
+		if err != nil || page < 0 {
+			break
+		}
+		s = screen{mode: modeList, page: page}
+		r.show(d, "")
+	case 'o':
+		id, err := r.resolve(e, c.Sender, s, arg[1:])
+		if err != nil {
+			r.fail(e, c, err)
+			return
+		}
+		record, err := r.Source.Get(c.Sender, id)
+		if err != nil {
+			r.fail(e, c, err)
+			return
+		}
+		s = screen{mode: modeItem, page: s.page, id: record.ID}
+		r.show(d, r.detail(e, lang, record))
+	case 'x':
+		if s.mode != modeItem {
+			break
+		}
+		s.mode = modeConfirm
+		r.show(d, r.text(e, lang, "question", "Delete this record?"))
+	case 'y':
+		if s.mode != modeConfirm {
+			break
+		}
+		if err := r.Source.Delete(c.Sender, s.id); err != nil {
+			r.fail(e, c, err)
+			return
+		}
+		s = screen{mode: modeList, page: s.page}
+		r.show(d, "")
+	case 'e':
+		field, err := strconv.Atoi(arg[1:])
+		if err != nil || field < 0 || s.mode != modeItem {
+			break
+		}
+		record, err := r.Source.Get(c.Sender, s.id)
+		if err != nil || field >= len(record.Fields) {
+			r.fail(e, c, err)
+			return
+		}
+		s.mode, s.field = modeEdit, field
+		r.show(d, r.text(e, lang, "prompt", "Type a new value:")+" "+e.flow.tr(lang, record.Fields[field].Name))
+	}
+	if s.mode == modeEdit {
+		d.input = e
+	} else {
+		d.input = nil
+	}
+	e.flow.setState(c.Sender.Recipient(), e.stateKey(), s.String())
+	e.flow.respond(c)
+	e.refresh(c)
+}
+
+/*
+	Filters the list page by a typed query, the other screens ignore it
+*/
+func (r *Records) search(e *Node, d *Dialog, m *tb.Message) bool {
+	if r.screen(e, m.Sender).mode != modeList {
+		return false
+	}
+	e.flow.setState(m.Sender.Recipient(), e.stateKey(), screen{mode: modeList}.String())
+	return e.filter(d, m)
+}
+
+/*
+	Passes a typed value of the edited field to the data source
+	and returns to the detail page
+*/
+func (r *Records) receive(e *Node, c *tb.Callback, d *Dialog, m *tb.Message) {
+	s := r.screen(e, m.Sender)
+	record, err := r.Source.Get(m.Sender, s.id)
+	if s.mode != modeEdit || err != nil || s.field >= len(record.Fields) {
+		d.input = nil
+		return
+	}
+	if err := r.Source.Update(m.Sender, s.id, record.Fields[s.field].Name, m.Text); err != nil {
+		r.show(d, r.text(e, d.Language, "invalid", "Invalid value, try again:")+" "+e.flow.tr(d.Language, record.Fields[s.field].Name))
+		e.update(m.Sender, d, e)
+		return
+	}
+	if updated, err := r.Source.Get(m.Sender, s.id); err == nil {
+		record = updated
+	}
+	d.input = nil
+	s.mode = modeItem
+	e.flow.setState(m.Sender.Recipient(), e.stateKey(), s.String())
+	r.show(d, r.detail(e, d.Language, record))
+	e.update(m.Sender, d, e)
+}
+
+/*
+	Formats a record for the caption of the detail page
+*/
+func (r *Records) detail(e *Node, lang string, record Record) string {
+	lines := []string{record.Title}
+	for _, field := range record.Fields {
+		lines = append(lines, e.flow.tr(lang, field.Name)+": "+field.Value)
+	}
+	return strings.Join(lines, "\n")
+}
+
+/*
+	Displays a text instead of the caption, an empty text restores the caption
+*/
+func (r *Records) show(d *Dialog, text string) {
+	if text == "" {
+		if d.question != "" && d.Message.Text == d.question {
+			d.Message.Text = d.caption
+		}
+		d.question = ""
+		return
+	}
+	if d.question == "" || d.Message.Text != d.question {
+		d.caption = d.Message.Text
+	}
+	d.question = text
+	d.Message.Text = text
+}
+
+/*
+	Reports a failure of the data source with an alert
+*/
+func (r *Records) fail(e *Node, c *tb.Callback, err error) {
+	if err != nil {
+		log.Println("failed to process a record", c.Sender.Recipient(), err)
+	}
+	resp := e.flow.response(c)
+	resp.Text = r.text(e, e.GetLanguage(c), "error", "Something went wrong")
+	resp.ShowAlert = true
+	e.flow.respond(c)
+}
@@ -0,0 +1,178 @@
+package menu
+
+import (
+	"github.com/pkg/errors"
+	tb "gopkg.in/tucnak/telebot.v2"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+/*
+	A data source that keeps records in memory
+*/
+type memSource struct {
+	records []Record
+}
+
+func (s *memSource) List(of tb.Recipient, offset, limit int) ([]Record, int, error) {
+	if offset >= len(s.records) {
+		return nil, len(s.records), nil
+	}
+	end := offset + limit
+	if end > len(s.records) {
+		end = len(s.records)
+	}
+	return s.records[offset:end], len(s.records), nil
+}
+
+func (s *memSource) Get(of tb.Recipient, id string) (Record, error) {
+	for _, record := range s.records {
+		if record.ID == id {
+			return record, nil
+		}
+	}
+	return Record{}, errors.New("no record " + id)
+}
+
+func (s *memSource) Delete(of tb.Recipient, id string) error {
+	return nil
+}
+
+func (s *memSource) Update(of tb.Recipient, id, field, value string) error {
+	return nil
+}
+
+func TestRecordIDs(t *testing.T) {
+	user := &tb.User{ID: 1}
+	long := strings.Repeat("x", 80)
+	tests := []struct {
+		name   string
+		id     string
+		hashed bool
+	}{
+		{"short id", "7", false},
+		{"id too long for callback data", long, true},
+		{"id at the limit", strings.Repeat("y", 49), false},
+		{"id just over the limit", strings.Repeat("y", 50), true},
+		{"id looking like a hash", "#7", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, api := newTestMenu(t, nil)
+			source := &memSource{records: []Record{{ID: "1", Title: "First"}, {ID: tt.id, Title: "Wanted"}}}
+			records := &Records{Source: source}
+			f.GetRoot().AddRecords("r", records).GetFlow().Build("en")
+			e, _ := f.Find("r")
+			d := startDialog(t, f, user)
+			f.handleCallback(newCallback(user, d.Message, e.id))
+
+			var button *tb.InlineButton
+			for _, row := range records.keyboard(e, user, "en") {
+				for i := range row {
+					if size := len("\f" + row[i].Unique + "|" + row[i].Data); size > maxCallbackData {
+						t.Errorf("callback data of %q takes %d bytes", row[i].Text, size)
+					}
+					if row[i].Text == "Wanted" {
+						button = &row[i]
+					}
+				}
+			}
+			if button == nil {
+				t.Fatal("no button of the record")
+			}
+			if hashed := !strings.HasSuffix(button.Data, ":o"+tt.id); hashed != tt.hashed {
+				t.Errorf("hashed %v; want %v", hashed, tt.hashed)
+			}
+			f.handleCallback(newCallback(user, d.Message, button.Data))
+			if s := records.screen(e, user); s.mode != modeItem || s.id != tt.id {
+				t.Errorf("screen %q with the record %q; want %q with %q", s.mode, s.id, modeItem, tt.id)
+			}
+			if answer, _ := api.last("answerCallbackQuery"); answer.params["show_alert"] == "true" {
+				t.Errorf("alert %q", answer.params["text"])
+			}
+		})
+	}
+}
+
+func TestRecordGone(t *testing.T) {
+	user := &tb.User{ID: 1}
+	f, api := newTestMenu(t, nil)
+	long := strings.Repeat("x", 80)
+	source := &memSource{records: []Record{{ID: long, Title: "Wanted"}}}
+	records := &Records{Source: source}
+	f.GetRoot().AddRecords("r", records).GetFlow().Build("en")
+	e, _ := f.Find("r")
+	d := startDialog(t, f, user)
+	f.handleCallback(newCallback(user, d.Message, e.id))
+
+	// the record is deleted elsewhere before its button is pressed
+	data := e.id + ":o" + records.ref(e, long)
+	source.records = nil
+	f.handleCallback(newCallback(user, d.Message, data))
+	if s := records.screen(e, user); s.mode != modeList {
+		t.Errorf("screen %q; want %q", s.mode, modeList)
+	}
+	answer, _ := api.last("answerCallbackQuery")
+	if answer.params["show_alert"] != "true" || answer.params["text"] != "Something went wrong" {
+		t.Errorf("answer %q with alert %q; want an alert", answer.params["text"], answer.params["show_alert"])
+	}
+	if _, err := records.resolve(e, user, records.screen(e, user), records.ref(e, long)); errors.Cause(err) != ErrRecordNotFound {
+		t.Errorf("error %v; want %v", err, ErrRecordNotFound)
+	}
+}
+
+func TestRecordPresses(t *testing.T) {
+	user := &tb.User{ID: 1}
+	records := make([]Record, 0, 12)
+	for i := 0; i < 12; i++ {
+		id := strconv.Itoa(i)
+		records = append(records, Record{ID: id, Title: "Record " + id, Fields: []Field{{Name: "name", Editable: true}}})
+	}
+	tests := []struct {
+		name    string
+		presses []string
+		want    screen
+	}{
+		{name: "next page", presses: []string{"p1"}, want: screen{mode: modeList, page: 1}},
+		{name: "negative page", presses: []string{"p1", "p-1"}, want: screen{mode: modeList, page: 1}},
+		{name: "page that is not a number", presses: []string{"p1", "pz"}, want: screen{mode: modeList, page: 1}},
+		{name: "edit a field", presses: []string{"o3", "e0"}, want: screen{mode: modeEdit, id: "3"}},
+		{name: "negative field", presses: []string{"o3", "e-1"}, want: screen{mode: modeItem, id: "3"}},
+		{name: "field out of range", presses: []string{"o3", "e1"}, want: screen{mode: modeItem, id: "3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _ := newTestMenu(t, nil)
+			r := &Records{Source: &memSource{records: records}, PageSize: 5}
+			f.GetRoot().AddRecords("r", r).GetFlow().Build("en")
+			e, _ := f.Find("r")
+			d := startDialog(t, f, user)
+			f.handleCallback(newCallback(user, d.Message, e.id))
+			for _, arg := range tt.presses {
+				f.handleCallback(newCallback(user, d.Message, e.id+":"+arg))
+			}
+			if s := r.screen(e, user); s != tt.want {
+				t.Errorf("screen %v; want %v", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseScreen(t *testing.T) {
+	tests := []struct {
+		value string
+		want  screen
+	}{
+		{"i|2|0|7", screen{mode: modeItem, page: 2, id: "7"}},
+		{"e|0|1|7|8", screen{mode: modeEdit, field: 1, id: "7|8"}},
+		{"", screen{mode: modeList}},
+		{"i|-1|0|7", screen{mode: modeList}},
+		{"e|0|-1|7", screen{mode: modeList}},
+	}
+	for _, tt := range tests {
+		if got := parseScreen(tt.value); got != tt.want {
+			t.Errorf("parseScreen(%q) = %v; want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package menu
+
+import (
+	"fmt"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+/*
+	Provider of parameters that are placed in a label template of a node for the user
+*/
+type LabelData func(e *Node, of tb.Recipient) []interface{}
+
+/*
+	Sets a provider of parameters for the label of the node
+	The translation of the node is used as a template, e.g. "Cart (%d)"
+	Returns the current node
+*/
+func (e *Node) SetLabelData(data LabelData) *Node {
+	e.labelData = data
+	return e
+}
+
+/*
+	Renders a label of the node for the user from the translated text
+*/
+func (e *Node) personalize(of tb.Recipient, lang, text string) string {
+	if e.labelData != nil {
+		if params := e.labelData(e, of); len(params) > 0 {
+			text = fmt.Sprintf(text, params...)
+		}
+	}
+	if e.IsStateful() {
+		text = e.label(of, lang, text)
+	}
+	return text
+}
+
+/*
+	Renders the page markup for the user
+*/
+func (e *Node) render(of tb.Recipient, lang string) *tb.ReplyMarkup {
+	markup, _ := e.view(of, lang)
+	return markup
+}
+
+/*
+	Renders the page markup for the user along with the nodes of its rows
+	Built markups are never sent as is, since telebot rewrites callback data
+	of the buttons it sends
+*/
+func (e *Node) view(of tb.Recipient, lang string) (*tb.ReplyMarkup, []*Node) {
+	e.flow.treeMx.RLock()
+	markup, rows := e.markups[lang], e.rows
+	e.flow.treeMx.RUnlock()
+	if markup == nil {
+		return nil, nil
+	}
+	rendered := *markup
+	rendered.InlineKeyboard = nil
+	rendered.ReplyKeyboard = nil
+	if e.widget != nil && !e.flow.replyMode {
+		// the widget keyboard goes above the sub nodes, e.g. a back button
+		rendered.InlineKeyboard = e.widget.keyboard(e, of, lang)
+	}
+	visible := make([]*Node, 0, len(rows))
+	for i, child := range rows {
+		if !child.IsVisible(of) {
+			continue
+		}
+		visible = append(visible, child)
+		if i < len(markup.InlineKeyboard) {
+			row := append([]tb.InlineButton(nil), markup.InlineKeyboard[i]...)
+			row[0].Text = child.personalize(of, lang, row[0].Text)
+			if r, ok := child.widget.(inliner); ok {
+				row = r.row(child, of, row[0].Text)
+			}
+			rendered.InlineKeyboard = append(rendered.InlineKeyboard, row)
+		}
+		if i < len(markup.ReplyKeyboard) {
+			row := append([]tb.ReplyButton(nil), markup.ReplyKeyboard[i]...)
+			row[0].Text = child.personalize(of, lang, row[0].Text)
+			rendered.ReplyKeyboard = append(rendered.ReplyKeyboard, row)
+		}
+	}
+	return &rendered, visible
+}
+
+/*
+	Copies a markup so it can be safely sent
+*/
+func cloneMarkup(markup *tb.ReplyMarkup) *tb.ReplyMarkup {
+	if markup == nil {
+		return nil
+	}
+	clone := *markup
+	clone.InlineKeyboard = make([][]tb.InlineButton, len(markup.InlineKeyboard))
+	for i, row := range markup.InlineKeyboard {
+		clone.InlineKeyboard[i] = append([]tb.InlineButton(nil), row...)
+	}
+	clone.ReplyKeyboard = make([][]tb.ReplyButton, len(markup.ReplyKeyboard))
+	for i, row := range markup.ReplyKeyboard {
+		clone.ReplyKeyboard[i] = append([]tb.ReplyButton(nil), row...)
+	}
+	return &clone
+}
@@ -0,0 +1,160 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+/*
+	Renders the menu as reply keyboards instead of inline buttons
+	Must be set before the menu is built
+	Widgets, e.g. calendars, keypads, ratings, carts and records, and type-to-search need inline keyboards
+	Presses arrive as text, so a single tb.OnText handler is registered that passes them to Menu.Process,
+	a tb.OnText handler registered later replaces it and must pass the messages to Menu.Process itself
+*/
+func (f *Menu) SetReplyKeyboard(enabled bool) *Menu {
+	f.replyMode = enabled
+	if enabled && f.bot != nil {
+		f.bot.Handle(tb.OnText, f.handleText)
+	}
+	return f
+}
+
+/*
+	Checks if the menu is rendered as reply keyboards
+*/
+func (f *Menu) IsReplyKeyboard() bool {
+	return f.replyMode
+}
+
+/*
+	Process a text message sent by pressing a reply keyboard button,
+	typed as a reply to an input node, a rating comment, a value of a record field or a search query
+	None of them reach the menu unless messages are passed here, e.g. with b.Handle(tb.OnText, ...),
+	buttons with templated labels work only here as well
+	Only texts sent to the chat a menu is in reach it, see SetInlineInput for menus attached to inline messages
+	Returns true only if the text matched a button on the user's current page,
+	was received by an input node or was searched for
+*/
+func (f *Menu) Process(m *tb.Message) bool {
+	if m == nil || m.Sender == nil || m.Text == "" {
+		return false
+	}
+	d, ok := f.GetActiveDialog(m.Sender.Recipient())
+	if !ok || d.Position == nil {
+		// the menu hasn't started for the user
+		return false
+	}
+	c := f.textCallback(m, m)
+	unlock := f.hold(c, d)
+	if f.inChat(d, m) {
+		if node := d.Position.match(m.Sender, d.Language, m.Text); node != nil {
+			defer unlock()
+			node.dispatch(c)
+			return true
+		}
+	}
+	unlock()
+	if w := f.waiting(m.Sender.Recipient(), m); w != nil {
+		f.activate(m.Sender, w)
+		reply := f.textCallback(m, nil)
+		defer f.hold(reply, w)()
+		// the menu message is read once the dialog is locked
+		reply.Message = w.Message
+		w.input.receive(reply, w, m)
+		return true
+	}
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	return f.inChat(d, m) && f.search(d, m)
+}
+
+/*
+	Finds the menu of a user that waits for a text reply in the chat of a message, a nil message matches any chat
+	The menu the user is interacting with goes first and then the latest one
+	Only internal use is intended
+*/
+func (f *Menu) waiting(user string, m *tb.Message) *Dialog {
+	dialogs := f.GetDialogs(user)
+	if d, ok := f.GetActiveDialog(user); ok {
+		dialogs = append(dialogs, d)
+	}
+	for i := len(dialogs) - 1; i >= 0; i-- {
+		if f.waits(dialogs[i], m) {
+			return dialogs[i]
+		}
+	}
+	return nil
+}
+
+/*
+	Checks if a menu waits for a text reply in the chat of a message
+	Only internal use is intended
+*/
+func (f *Menu) waits(d *Dialog, m *tb.Message) bool {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	return d.input != nil && (m == nil || f.inChat(d, m))
+}
+
+/*
+	Checks if a text was sent to the chat a menu is in
+	Menus attached to inline messages take texts from any chat only when enabled with SetInlineInput
+	The caller must hold the lock of the dialog
+	Only internal use is intended
+*/
+func (f *Menu) inChat(d *Dialog, m *tb.Message) bool {
+	if isInline(d.Message) {
+		return f.inlineInput
+	}
+	return m.Chat != nil && d.Message.Chat != nil && m.Chat.ID == d.Message.Chat.ID
+}
+
+/*
+	A default handler that aggregates reply keyboard presses
+	and routes them by their labels
+*/
+func (f *Menu) handleText(m *tb.Message) {
+	f.Process(m)
+}
+
+/*
+	Finds a child of the page by its label rendered for the user
+*/
+func (e *Node) match(of tb.Recipient, lang, text string) *Node {
+	markup, rows := e.view(of, lang)
+	if markup == nil {
+		return nil
+	}
+	for i, row := range markup.ReplyKeyboard {
+		if i < len(rows) && len(row) > 0 && row[0].Text == text {
+			return rows[i]
+		}
+	}
+	return nil
+}
+
+/*
+	Creates a reply keyboard markup for the page in a specified locale
+	Link nodes are skipped since reply buttons cannot carry them
+*/
+func (e *Node) buildReply(lang string) {
+	buttons := make([][]tb.ReplyButton, 0, len(e.nodes))
+	e.rows = make([]*Node, 0, len(e.nodes))
+	for _, child := range e.nodes {
+		if child.IsLink() {
+			continue
+		}
+		text := e.flow.tr(lang, child.labelPath(child.path))
+		btn := []tb.ReplyButton{
+			{
+				Text: text,
+			},
+		}
+		e.rows = append(e.rows, child)
+		buttons = append(buttons, btn)
+	}
+	e.markups[lang] = &tb.ReplyMarkup{
+		ReplyKeyboard:       buttons,
+		ResizeReplyKeyboard: true,
+	}
+}
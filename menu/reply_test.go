@@ -0,0 +1,120 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"strings"
+	"testing"
+)
+
+func TestReplyAnswers(t *testing.T) {
+	alice := &tb.User{ID: 1}
+	bob := &tb.User{ID: 2}
+	f, api := newTestMenu(t, map[string]string{"flow/a": "Alpha", "flow/note": "Note for %d"})
+	press := func(user *tb.User) *tb.Message {
+		return &tb.Message{Sender: user, Chat: &tb.Chat{ID: int64(user.ID)}, Text: "Alpha"}
+	}
+	f.SetReplyKeyboard(true).GetRoot().Add("a", func(e *Node, c *tb.Callback) int {
+		e.Notify(c, "flow/note", c.Sender.ID)
+		if c.Sender == alice {
+			// another user presses a button before the answer is sent
+			f.Process(press(bob))
+		}
+		return Stay
+	}).GetFlow().Build("en")
+	startDialog(t, f, alice)
+	startDialog(t, f, bob)
+	api.reset()
+
+	if !f.Process(press(alice)) {
+		t.Fatal("the press is not processed")
+	}
+	notes := make(map[string]string)
+	for _, call := range api.calls {
+		if call.method == "sendMessage" && strings.HasPrefix(call.params["text"], "Note") {
+			notes[call.params["chat_id"]] = call.params["text"]
+		}
+	}
+	want := map[string]string{"1": "Note for 1", "2": "Note for 2"}
+	if len(notes) != len(want) {
+		t.Errorf("notes %v; want %v", notes, want)
+	}
+	for chat, text := range want {
+		if notes[chat] != text {
+			t.Errorf("chat %s got %q; want %q", chat, notes[chat], text)
+		}
+	}
+
+	// a label typed in another chat does not press the button
+	if f.Process(&tb.Message{Sender: alice, Chat: &tb.Chat{ID: -100}, Text: "Alpha"}) {
+		t.Error("a text from another chat is processed")
+	}
+}
+
+func TestAnswerKey(t *testing.T) {
+	f, _ := newTestMenu(t, nil)
+	tests := []struct {
+		name string
+		c    *tb.Callback
+		text bool
+	}{
+		{"real callback", &tb.Callback{ID: "42"}, false},
+		{"callback without an id", &tb.Callback{}, true},
+		{"text", f.textCallback(&tb.Message{Text: "Alpha"}, nil), true},
+	}
+	seen := make(map[string]bool)
+	for _, tt := range tests {
+		key := f.answerKey(tt.c)
+		if key == "" || seen[key] {
+			t.Errorf("%s: key %q is empty or not unique", tt.name, key)
+		}
+		seen[key] = true
+		if text := strings.HasPrefix(key, textPrefix); text != tt.text {
+			t.Errorf("%s: text %v; want %v", tt.name, text, tt.text)
+		}
+	}
+}
+
+func TestProcessChat(t *testing.T) {
+	user := &tb.User{ID: 1}
+	private := &tb.Chat{ID: 1}
+	other := &tb.Chat{ID: -100, Type: tb.ChatGroup}
+	tests := []struct {
+		name        string
+		inline      bool
+		inlineInput bool
+		chat        *tb.Chat
+		received    bool
+	}{
+		{name: "the chat of the menu", chat: private, received: true},
+		{name: "another chat", chat: other, received: false},
+		{name: "no chat", chat: nil, received: false},
+		{name: "inline menu", inline: true, chat: private, received: false},
+		{name: "inline menu with inline input", inline: true, inlineInput: true, chat: private, received: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _ := newTestMenu(t, map[string]string{"flow/in": "Input", "flow/prompt": "Type it"})
+			received := ""
+			f.SetInlineInput(tt.inlineInput).GetRoot().AddInput("in", "flow/prompt", "", func(e *Node, m *tb.Message) bool {
+				received = m.Text
+				return true
+			}, nil).GetFlow().Build("en")
+			in, _ := f.Find("in")
+			var msg *tb.Message
+			if tt.inline {
+				msg = attachInline(t, f)
+			} else {
+				msg = startDialog(t, f, user).Message
+			}
+			f.handleCallback(newCallback(user, msg, in.id))
+			if !f.IsWaitingInput(user) {
+				t.Fatal("the input node does not wait for a reply")
+			}
+
+			processed := f.Process(&tb.Message{Sender: user, Chat: tt.chat, Text: "hello"})
+			if processed != tt.received || (received == "hello") != tt.received {
+				t.Errorf("processed %v and received %q; want %v", processed, received, tt.received)
+			}
+		})
+	}
+}
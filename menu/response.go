@@ -0,0 +1,149 @@
+package menu
+
+import (
+	"fmt"
+	tb "gopkg.in/tucnak/telebot.v2"
+	"log"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+/*
+	A prefix of IDs of callbacks made up for texts the user sends,
+	e.g. reply keyboard presses and replies to input nodes
+*/
+const textPrefix = "_text_"
+
+/*
+	Creates a callback for a text the user has sent to a menu message
+	Every text gets an ID of its own, so pending answers of different users never mix
+	Only internal use is intended
+*/
+func (f *Menu) textCallback(m, msg *tb.Message) *tb.Callback {
+	return &tb.Callback{ID: f.textID(), Sender: m.Sender, Message: msg, Data: m.Text}
+}
+
+/*
+	Generates a unique ID of a callback made up for a text
+	Only internal use is intended
+*/
+func (f *Menu) textID() string {
+	return textPrefix + strconv.FormatUint(uint64(atomic.AddUint32(&f.texts, 1)), 10)
+}
+
+/*
+	Gets the key of a pending answer, a callback without an ID gets one of its own
+	Only internal use is intended
+*/
+func (f *Menu) answerKey(c *tb.Callback) string {
+	if c.ID == "" {
+		c.ID = f.textID()
+	}
+	return c.ID
+}
+
+/*
+	Gets a pending answer for a callback or creates an empty one
+	Only internal use is intended
+*/
+func (f *Menu) response(c *tb.Callback) *tb.CallbackResponse {
+	key := f.answerKey(c)
+	f.mx.Lock()
+	resp, ok := f.responses[key]
+	if !ok {
+		resp = &tb.CallbackResponse{}
+		f.responses[key] = resp
+	}
+	f.mx.Unlock()
+	return resp
+}
+
+/*
+	Answers a callback exactly once with whatever the endpoint has set
+	Only internal use is intended
+*/
+func (f *Menu) respond(c *tb.Callback) {
+	key := f.answerKey(c)
+	f.mx.Lock()
+	resp, ok := f.responses[key]
+	delete(f.responses, key)
+	f.mx.Unlock()
+	var err error
+	if strings.HasPrefix(key, textPrefix) {
+		// a text is not a real callback so the answer is sent as a regular message
+		// to the chat the text was sent to
+		var to tb.Recipient = c.Sender
+		if c.Message != nil && c.Message.Chat != nil {
+			to = c.Message.Chat
+		}
+		if ok && resp.Text != "" {
+			_, err = f.bot.Send(to, resp.Text, tb.Silent)
+		}
+	} else if ok {
+		err = f.bot.Respond(c, resp)
+	} else {
+		err = f.bot.Respond(c)
+	}
+	if err != nil {
+		log.Println("failed to respond", c.Sender.ID, err)
+	}
+}
+
+/*
+	Translates a locale path into the language of the user's dialog
+	Params are automatically placed in the text if provided
+*/
+func (e *Node) Translate(c *tb.Callback, textPath string, params ...interface{}) string {
+	text := e.flow.tr(e.GetLanguage(c), textPath)
+	if len(params) > 0 {
+		text = fmt.Sprintf(text, params...)
+	}
+	return text
+}
+
+/*
+	Shows a notification at the top of the chat once the endpoint returns
+	The text is a locale path that is translated into the user's language
+*/
+func (e *Node) Notify(c *tb.Callback, textPath string, params ...interface{}) *Node {
+	resp := e.flow.response(c)
+	resp.Text = e.Translate(c, textPath, params...)
+	resp.ShowAlert = false
+	return e
+}
+
+/*
+	Shows a modal alert once the endpoint returns
+	The text is a locale path that is translated into the user's language
+*/
+func (e *Node) Alert(c *tb.Callback, textPath string, params ...interface{}) *Node {
+	resp := e.flow.response(c)
+	resp.Text = e.Translate(c, textPath, params...)
+	resp.ShowAlert = true
+	return e
+}
+
+/*
+	Sets a URL that will be opened by the user's client once the endpoint returns
+*/
+func (e *Node) OpenURL(c *tb.Callback, url string) *Node {
+	e.flow.response(c).URL = url
+	return e
+}
+
+/*
+	Replaces the callback answer with a raw response
+	The text is sent as is, without translation
+*/
+func (e *Node) Respond(c *tb.Callback, resp *tb.CallbackResponse) *Node {
+	if resp == nil {
+		return e
+	}
+	answer := *resp
+	key := e.flow.answerKey(c)
+	e.flow.mx.Lock()
+	e.flow.responses[key] = &answer
+	e.flow.mx.Unlock()
+	return e
+}
@@ -0,0 +1,352 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"log"
+	"strings"
+)
+
+/*
+	The default locale path (relative to the menu id) of the directory with texts of search results,
+	"search/results", "search/empty", "search/back" and "search/clear"
+	Missing texts are displayed in English
+*/
+const DefaultSearchText = "search"
+
+/*
+	The default amount of search results displayed at once
+*/
+const DefaultSearchLimit = 20
+
+const (
+	searchKey = "_search_"
+	// an argument of widget buttons that clear the query filtering the page
+	clearQuery = "~q"
+)
+
+/*
+	A widget that handles text typed by the user while its page is open as a search query,
+	e.g. to filter items it renders on the fly
+*/
+type searcher interface {
+	search(e *Node, d *Dialog, m *tb.Message) bool
+}
+
+/*
+	Filters the current page by text the user types while the menu is open and passed to Menu.Process
+*/
+func (f *Menu) SetTypeToSearch(enabled bool) *Menu {
+	f.typeToSearch = enabled
+	return f
+}
+
+/*
+	Sets the amount of search results displayed at once
+*/
+func (f *Menu) SetSearchLimit(limit int) *Menu {
+	f.searchLimit = limit
+	return f
+}
+
+/*
+	Sets a locale path of the directory with texts of search results
+*/
+func (f *Menu) SetSearchTexts(path string) *Menu {
+	f.searchPath = path
+	return f
+}
+
+/*
+	Creates a new search node in the flow
+	that asks the user to type a query and searches the page the node is on
+	The prompt is a locale path that replaces the caption while waiting
+	Queries arrive through Menu.Process
+*/
+func (f *Menu) NewSearchNode(text, promptPath string) *Node {
+	return newSearchNode(f, text, promptPath, f.root)
+}
+
+/*
+	Adds a new search node to the current node
+	Returns the current node
+*/
+func (e *Node) AddSearch(text, promptPath string) *Node {
+	e.AddManySub([]*Node{newSearchNode(e.flow, text, promptPath, e)})
+	return e
+}
+
+/*
+	Creates a new search node with a cancel sub node
+	Only internal use is intended
+*/
+func newSearchNode(root *Menu, text, promptPath string, prev *Node) *Node {
+	e := newWidgetNode(root, text, &finder{}, prev)
+	e.question = promptPath
+	no := newNode(root, DefaultCancelText, nil, e)
+	no.kind = kindConfirmNo
+	e.nodes = []*Node{no}
+	return e
+}
+
+/*
+	Finds the nodes under the page whose labels in a specified language contain the query
+	Hidden nodes and buttons of confirmations are skipped
+	along with every node under a page the user may not open
+*/
+func (e *Node) Lookup(of tb.Recipient, lang, query string) []*Node {
+	query = strings.ToLower(strings.TrimSpace(query))
+	found := make([]*Node, 0)
+	if query == "" {
+		return found
+	}
+	// predicates and label data are user code, so they are called without the lock
+	e.flow.treeMx.RLock()
+	nodes := make([]*Node, 0)
+	e.walk(e.path, func(child *Node, path string) {
+		nodes = append(nodes, child)
+	})
+	e.flow.treeMx.RUnlock()
+	// the pages above every node are checked once
+	memo := make(map[*Node]*Node)
+	for _, child := range nodes {
+		if child.kind == kindConfirmYes || child.kind == kindConfirmNo || !child.IsVisible(of) {
+			continue
+		}
+		if b := child.blocked(of, memo); b != nil && b != child {
+			continue
+		}
+		if matchQuery(child.searchLabel(of, lang), query) {
+			found = append(found, child)
+		}
+	}
+	return found
+}
+
+/*
+	Checks if a label contains a query regardless of case
+*/
+func matchQuery(label, query string) bool {
+	return strings.Contains(strings.ToLower(label), strings.ToLower(strings.TrimSpace(query)))
+}
+
+/*
+	Gets the query that filters items of the widget page for the user
+*/
+func (e *Node) query(of tb.Recipient) string {
+	value, _ := e.flow.getState(of.Recipient(), searchKey+e.id)
+	return value
+}
+
+/*
+	Sets the query that filters items of the widget page for the user, an empty query shows every item
+*/
+func (e *Node) setQuery(of tb.Recipient, query string) {
+	e.flow.setState(of.Recipient(), searchKey+e.id, strings.TrimSpace(query))
+}
+
+/*
+	Filters items of the widget page by a typed query and redraws the page
+	Only internal use is intended
+*/
+func (e *Node) filter(d *Dialog, m *tb.Message) bool {
+	e.setQuery(m.Sender, m.Text)
+	e.update(m.Sender, d, e)
+	return true
+}
+
+/*
+	Renders a button that clears the query filtering the widget page,
+	nothing is rendered while the page is not filtered
+	Only internal use is intended
+*/
+func (e *Node) queryRow(of tb.Recipient, lang string) []tb.InlineButton {
+	query := e.query(of)
+	if query == "" {
+		return nil
+	}
+	text := e.flow.trOr(lang, e.flow.searchPath+"/clear", "✕ Clear search") + " «" + query + "»"
+	return []tb.InlineButton{e.widgetButton(text, clearQuery)}
+}
+
+/*
+	Renders a label of the node the way it is displayed to the user
+*/
+func (e *Node) searchLabel(of tb.Recipient, lang string) string {
+	return e.personalize(of, lang, e.flow.tr(lang, e.labelPath(e.path)))
+}
+
+/*
+	Displays nodes under a page that match a query as a temporary page
+	Only internal use is intended
+*/
+func (f *Menu) find(of tb.Recipient, d *Dialog, scope *Node, query string) {
+	f.setState(of.Recipient(), searchKey, scope.id+"|"+query)
+	from := d.Position
+	if f.results.update(of, d, f.results) && from != nil {
+		if _, ok := from.widget.(*finder); !ok && from != f.results {
+			f.remember(d, from, f.results)
+		}
+	}
+}
+
+/*
+	Treats text the user has typed while the menu is open as a search query
+	Only internal use is intended
+*/
+func (f *Menu) search(d *Dialog, m *tb.Message) bool {
+	if !f.typeToSearch || f.replyMode || d.Position == nil {
+		return false
+	}
+	page := d.Position
+	if s, ok := page.widget.(searcher); ok {
+		return s.search(page, d, m)
+	}
+	if page.IsWidget() && len(page.GetNodes()) < 1 {
+		// nothing to search on a page that is all widget
+		return false
+	}
+	f.find(m.Sender, d, page, m.Text)
+	return true
+}
+
+/*
+	A search node that asks for a query
+*/
+type finder struct{}
+
+/*
+	A search node has nothing but the cancel button
+*/
+func (s *finder) keyboard(e *Node, of tb.Recipient, lang string) [][]tb.InlineButton {
+	return nil
+}
+
+/*
+	Asks the user to type a query
+*/
+func (s *finder) open(e *Node, c *tb.Callback, d *Dialog) {
+	d.caption = d.Message.Text
+	d.question = e.Translate(c, e.question)
+	d.Message.Text = d.question
+	d.input = e
+}
+
+/*
+	A search node has no buttons of its own
+*/
+func (s *finder) press(e *Node, c *tb.Callback, arg string) {
+	e.flow.respond(c)
+}
+
+/*
+	Searches the page the search node is on
+*/
+func (s *finder) receive(e *Node, c *tb.Callback, d *Dialog, m *tb.Message) {
+	d.input = nil
+	scope := e.prev
+	if scope == nil {
+		scope = e.flow.root
+	}
+	e.flow.find(m.Sender, d, scope, m.Text)
+}
+
+/*
+	A temporary page of search results
+*/
+type results struct{}
+
+/*
+	Gets the page that was searched and the query
+*/
+func (s *results) query(e *Node, of tb.Recipient) (*Node, string) {
+	value, _ := e.flow.getState(of.Recipient(), searchKey)
+	parts := strings.SplitN(value, "|", 2)
+	if len(parts) < 2 {
+		return e.flow.root, ""
+	}
+	scope, ok := e.flow.Search(parts[0])
+	if !ok {
+		scope = e.flow.root
+	}
+	return scope, parts[1]
+}
+
+/*
+	Gets the matches to display
+*/
+func (s *results) matches(e *Node, of tb.Recipient, lang string) (*Node, string, []*Node) {
+	scope, query := s.query(e, of)
+	found := scope.Lookup(of, lang, query)
+	limit := e.flow.searchLimit
+	if limit < 1 {
+		limit = DefaultSearchLimit
+	}
+	if len(found) > limit {
+		found = found[:limit]
+	}
+	return scope, query, found
+}
+
+/*
+	Displays the query and whether anything was found
+*/
+func (s *results) caption(e *Node, of tb.Recipient, lang string) string {
+	_, query, found := s.matches(e, of, lang)
+	if len(found) < 1 {
+		return e.flow.trOr(lang, e.flow.searchPath+"/empty", "Nothing found for") + " «" + query + "»"
+	}
+	return e.flow.trOr(lang, e.flow.searchPath+"/results", "Results for") + " «" + query + "»"
+}
+
+/*
+	Renders a button for every match that leads right to the node
+	Matches deeper in the tree are prefixed with the label of their parent
+*/
+func (s *results) keyboard(e *Node, of tb.Recipient, lang string) [][]tb.InlineButton {
+	scope, _, found := s.matches(e, of, lang)
+	rows := make([][]tb.InlineButton, 0, len(found)+1)
+	for _, match := range found {
+		text := match.searchLabel(of, lang)
+		if match.prev != nil && match.prev != scope {
+			text = match.prev.searchLabel(of, lang) + " › " + text
+		}
+		if match.IsLink() {
+			rows = append(rows, []tb.InlineButton{match.linkButton(text)})
+			continue
+		}
+		rows = append(rows, []tb.InlineButton{{Unique: e.flow.unique(), Text: text, Data: match.id}})
+	}
+	back := e.flow.trOr(lang, e.flow.searchPath+"/back", "« Back")
+	return append(rows, []tb.InlineButton{e.widgetButton(back, "back")})
+}
+
+/*
+	Goes back to the searched page
+*/
+func (s *results) press(e *Node, c *tb.Callback, arg string) {
+	e.flow.respond(c)
+	if arg != "back" {
+		return
+	}
+	d, ok := e.flow.dialogOf(c)
+	if !ok {
+		log.Println(c.Sender.ID, "does not exist")
+		return
+	}
+	if d.Previous() == nil {
+		// the history is disabled or too short
+		scope, _ := s.query(e, c.Sender)
+		e.update(c.Sender, d, scope)
+		return
+	}
+	e.back(c)
+}
+
+/*
+	Searches the same page again with a new query
+*/
+func (s *results) search(e *Node, d *Dialog, m *tb.Message) bool {
+	scope, _ := s.query(e, m.Sender)
+	e.flow.find(m.Sender, d, scope, m.Text)
+	return true
+}
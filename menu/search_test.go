@@ -0,0 +1,151 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	user := &tb.User{ID: 1}
+	f, _ := newTestMenu(t, map[string]string{
+		"flow/apple":          "Apple",
+		"flow/banana":         "Banana",
+		"flow/fruits":         "Fruits",
+		"flow/fruits/apricot": "Apricot",
+		"flow/cherry":         "Cherry",
+		"flow/admin":          "Admin",
+		"flow/admin/wipe":     "Wipe",
+		"flow/secret":         "Secret",
+		"flow/secret/vault":   "Vault",
+	})
+	hidden := func(e *Node, of tb.Recipient) bool { return false }
+	// predicates are user code that may touch the tree
+	touching := func(e *Node, of tb.Recipient) bool {
+		e.GetFlow().GetRoot().Remove(nil)
+		return true
+	}
+	f.GetRoot().
+		Add("apple", nil).
+		AddManySub([]*Node{f.NewNode("banana", nil).SetVisible(hidden)}).
+		AddWith("fruits", nil, f.NewNode("apricot", nil)).
+		AddManySub([]*Node{f.NewNode("cherry", nil).SetVisible(touching)}).
+		AddManySub([]*Node{f.NewNode("admin", nil).SetGuard(hidden, "").AddManySub([]*Node{f.NewNode("wipe", nil)})}).
+		AddManySub([]*Node{f.NewNode("secret", nil).SetVisible(hidden).AddManySub([]*Node{f.NewNode("vault", nil)})}).
+		GetFlow().Build("en")
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"ap", []string{"apple", "apricot"}},
+		{"  APPLE ", []string{"apple"}},
+		{"banana", nil},
+		{"cherry", []string{"cherry"}},
+		// a guarded page is listed like its button, the nodes under it are not
+		{"admin", []string{"admin"}},
+		{"wipe", nil},
+		{"vault", nil},
+		{"", nil},
+		{"kiwi", nil},
+	}
+	for _, tt := range tests {
+		found := f.GetRoot().Lookup(user, "en", tt.query)
+		if len(found) != len(tt.want) {
+			t.Errorf("Lookup(%q) found %d nodes; want %d", tt.query, len(found), len(tt.want))
+			continue
+		}
+		for i, e := range found {
+			if e.text != tt.want[i] {
+				t.Errorf("Lookup(%q) found %q at %d; want %q", tt.query, e.text, i, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestTypeToSearch(t *testing.T) {
+	user := &tb.User{ID: 1}
+	texts := map[string]string{
+		"flow/apple":         "Apple",
+		"flow/apricot":       "Apricot",
+		"flow/rate":          "Rate",
+		"flow/basket":        "Basket",
+		"flow/basket/back":   "Back",
+		"flow/records":       "Records",
+		"flow/shop":          "Shop",
+		"flow/shop/tea":      "Tea",
+		"flow/shop/coffee":   "Coffee",
+		"flow/shop/tea-cake": "Tea cake",
+	}
+	tests := []struct {
+		name      string
+		page      string
+		query     string
+		processed bool
+		position  string
+		buttons   int
+	}{
+		{name: "plain page", page: "", query: "ap", processed: true, position: "flow/search", buttons: 3},
+		{name: "results are limited", page: "", query: "a", processed: true, position: "flow/search", buttons: 3},
+		{name: "widget page without items", page: "rate", query: "ap", processed: false, position: "flow/rate"},
+		{name: "widget page with sub nodes", page: "basket", query: "back", processed: true, position: "flow/search", buttons: 2},
+		{name: "cart lines", page: "shop/summary", query: "tea", processed: true, position: "flow/shop/summary", buttons: 4},
+		{name: "records", page: "records", query: "wor", processed: true, position: "flow/records", buttons: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _ := newTestMenu(t, texts)
+			cart := f.NewCart("shop", nil)
+			source := &memSource{records: []Record{{ID: "1", Title: "Home"}, {ID: "2", Title: "Work"}, {ID: "3", Title: "Cottage"}}}
+			f.SetTypeToSearch(true).SetSearchLimit(2).GetRoot().
+				Add("apple", nil).
+				Add("apricot", nil).
+				AddManySub([]*Node{f.NewRatingNode("rate", &Rating{})}).
+				AddManySub([]*Node{f.NewRatingNode("basket", &Rating{}).AddManySub([]*Node{f.NewBackNode("back")})}).
+				AddRecords("records", &Records{Source: source}).
+				AddWith("shop", nil, cart.NewItemNode("tea"), cart.NewItemNode("coffee"), cart.NewItemNode("tea-cake"), cart.NewSummaryNode("summary")).
+				GetFlow().Build("en")
+			d := startDialog(t, f, user)
+			for _, item := range []string{"shop/tea", "shop/coffee", "shop/tea-cake"} {
+				e, _ := f.Find(item)
+				cart.SetQuantity(user, e, 1)
+			}
+			if page, ok := f.Find(tt.page); ok && page != f.root {
+				f.handleCallback(newCallback(user, d.Message, page.id))
+			}
+			processed := f.Process(&tb.Message{Sender: user, Chat: d.Message.Chat, Text: tt.query})
+			if processed != tt.processed {
+				t.Errorf("processed %v; want %v", processed, tt.processed)
+			}
+			if d.Position.path != tt.position {
+				t.Errorf("position %q; want %q", d.Position.path, tt.position)
+			}
+			if !tt.processed {
+				return
+			}
+			// the buttons of the filtered items or matches and a back or clear button
+			if rows := d.Position.render(user, "en").InlineKeyboard; len(rows) != tt.buttons {
+				t.Errorf("%d rows; want %d", len(rows), tt.buttons)
+			}
+		})
+	}
+}
+
+func TestClearQuery(t *testing.T) {
+	user := &tb.User{ID: 1}
+	f, _ := newTestMenu(t, map[string]string{"flow/records": "Records"})
+	source := &memSource{records: []Record{{ID: "1", Title: "Home"}, {ID: "2", Title: "Work"}}}
+	f.SetTypeToSearch(true).GetRoot().AddRecords("records", &Records{Source: source}).GetFlow().Build("en")
+	e, _ := f.Find("records")
+	d := startDialog(t, f, user)
+	f.handleCallback(newCallback(user, d.Message, e.id))
+	f.Process(&tb.Message{Sender: user, Chat: d.Message.Chat, Text: "work"})
+	if query := e.query(user); query != "work" {
+		t.Fatalf("query %q; want %q", query, "work")
+	}
+	f.handleCallback(newCallback(user, d.Message, e.id+":"+clearQuery))
+	if query := e.query(user); query != "" {
+		t.Errorf("query %q after clearing", query)
+	}
+	if rows := e.render(user, "en").InlineKeyboard; len(rows) != 2 {
+		t.Errorf("%d rows; want every record", len(rows))
+	}
+}
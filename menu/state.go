@@ -0,0 +1,315 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"log"
+)
+
+/*
+	A store that keeps the state of toggles, checkboxes and radio groups per user
+	By default the state is kept in the dialog the user is interacting with and lives as long as the dialog,
+	every open menu of the user has a state of its own
+*/
+type StateStore interface {
+	GetState(user, key string) (string, bool)
+	SetState(user, key, value string)
+}
+
+/*
+	Hook that triggers when a user changes a state of a toggle, checkbox or radio node
+*/
+type ChangeHook func(e *Node, c *tb.Callback, checked bool)
+
+/*
+	Marks that are used to render stateful nodes in a specific language
+*/
+type Marks struct {
+	ToggleOn    string
+	ToggleOff   string
+	CheckboxOn  string
+	CheckboxOff string
+	RadioOn     string
+	RadioOff    string
+}
+
+/*
+	Marks that are used when a language has no marks of its own
+*/
+var DefaultMarks = Marks{
+	ToggleOn:    "ON",
+	ToggleOff:   "OFF",
+	CheckboxOn:  "✅",
+	CheckboxOff: "⬜",
+	RadioOn:     "🔘",
+	RadioOff:    "⚪",
+}
+
+const (
+	stateOn     = "1"
+	stateOff    = "0"
+	radioPrefix = "_radio_"
+)
+
+/*
+	Sets a custom store for the state of stateful nodes
+	Nil store keeps the state in dialogs
+*/
+func (f *Menu) SetStateStore(store StateStore) *Menu {
+	f.store = store
+	return f
+}
+
+/*
+	Sets marks for stateful nodes in a specified locale
+*/
+func (f *Menu) SetMarks(lang string, marks Marks) *Menu {
+	f.mx.Lock()
+	f.marks[lang] = marks
+	f.mx.Unlock()
+	return f
+}
+
+/*
+	Gets marks for stateful nodes in a specified locale
+*/
+func (f *Menu) GetMarks(lang string) Marks {
+	f.mx.RLock()
+	marks, ok := f.marks[lang]
+	f.mx.RUnlock()
+	if !ok {
+		return DefaultMarks
+	}
+	return marks
+}
+
+/*
+	Reads a state value of a user from the dialog the user is interacting with
+	Only internal use is intended
+*/
+func (f *Menu) getState(user, key string) (string, bool) {
+	if f.store != nil {
+		return f.store.GetState(user, key)
+	}
+	f.mx.RLock()
+	defer f.mx.RUnlock()
+	d, ok := f.current(user)
+	if !ok || d.State == nil {
+		return "", false
+	}
+	value, ok := d.State[key]
+	return value, ok
+}
+
+/*
+	Writes a state value of a user to the dialog the user is interacting with
+	Only internal use is intended
+*/
+func (f *Menu) setState(user, key, value string) {
+	if f.store != nil {
+		f.store.SetState(user, key, value)
+		return
+	}
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	d, ok := f.current(user)
+	if !ok {
+		return
+	}
+	if d.State == nil {
+		d.State = make(map[string]string)
+	}
+	d.State[key] = value
+}
+
+/*
+	Copies the state of the dialog a user is interacting with for a new menu
+	Only internal use is intended
+*/
+func (f *Menu) copyState(user string) map[string]string {
+	f.mx.RLock()
+	defer f.mx.RUnlock()
+	d, ok := f.current(user)
+	if !ok || d.State == nil {
+		return nil
+	}
+	state := make(map[string]string, len(d.State))
+	for key, value := range d.State {
+		state[key] = value
+	}
+	return state
+}
+
+/*
+	Creates a new toggle node in the flow
+	that is rendered with an on/off suffix
+*/
+func (f *Menu) NewToggleNode(text string, hook ChangeHook) *Node {
+	return newStateNode(f, text, kindToggle, "", hook, f.root)
+}
+
+/*
+	Creates a new checkbox node in the flow
+	that is rendered with a check mark
+*/
+func (f *Menu) NewCheckboxNode(text string, hook ChangeHook) *Node {
+	return newStateNode(f, text, kindCheckbox, "", hook, f.root)
+}
+
+/*
+	Creates a new radio node in the flow
+	Only one node of the same group on a page can be selected
+*/
+func (f *Menu) NewRadioNode(text, group string, hook ChangeHook) *Node {
+	return newStateNode(f, text, kindRadio, group, hook, f.root)
+}
+
+/*
+	Creates a new stateful node
+	Only internal use is intended
+*/
+func newStateNode(root *Menu, text string, kind int, group string, hook ChangeHook, prev *Node) *Node {
+	e := newNode(root, text, nil, prev)
+	e.kind = kind
+	e.group = group
+	e.onChange = hook
+	return e
+}
+
+/*
+	Adds a new toggle node to the current node
+	Returns the current node
+*/
+func (e *Node) AddToggle(text string, hook ChangeHook) *Node {
+	e.AddManySub([]*Node{newStateNode(e.flow, text, kindToggle, "", hook, e)})
+	return e
+}
+
+/*
+	Adds a new checkbox node to the current node
+	Returns the current node
+*/
+func (e *Node) AddCheckbox(text string, hook ChangeHook) *Node {
+	e.AddManySub([]*Node{newStateNode(e.flow, text, kindCheckbox, "", hook, e)})
+	return e
+}
+
+/*
+	Adds a new radio node to the current node
+	Returns the current node
+*/
+func (e *Node) AddRadio(text, group string, hook ChangeHook) *Node {
+	e.AddManySub([]*Node{newStateNode(e.flow, text, kindRadio, group, hook, e)})
+	return e
+}
+
+/*
+	Checks if the node is stateful (toggle, checkbox or radio)
+*/
+func (e *Node) IsStateful() bool {
+	return e.kind == kindToggle || e.kind == kindCheckbox || e.kind == kindRadio
+}
+
+/*
+	Gets the state of a stateful node for the user
+*/
+func (e *Node) IsChecked(of tb.Recipient) bool {
+	if e.kind == kindRadio {
+		value, _ := e.flow.getState(of.Recipient(), e.radioKey())
+		return value == e.id
+	}
+	value, _ := e.flow.getState(of.Recipient(), e.id)
+	return value == stateOn
+}
+
+/*
+	Sets the state of a stateful node for the user
+	Selecting a radio node unselects the rest of its group
+*/
+func (e *Node) SetChecked(of tb.Recipient, checked bool) *Node {
+	if e.kind == kindRadio {
+		if checked {
+			e.flow.setState(of.Recipient(), e.radioKey(), e.id)
+		} else if e.IsChecked(of) {
+			e.flow.setState(of.Recipient(), e.radioKey(), "")
+		}
+		return e
+	}
+	value := stateOff
+	if checked {
+		value = stateOn
+	}
+	e.flow.setState(of.Recipient(), e.id, value)
+	return e
+}
+
+/*
+	A key of the radio group the node belongs to
+	Groups are scoped by the parent page
+*/
+func (e *Node) radioKey() string {
+	if e.prev == nil {
+		return radioPrefix + e.group
+	}
+	return radioPrefix + e.prev.id + "_" + e.group
+}
+
+/*
+	Renders a label of a stateful node for the user
+*/
+func (e *Node) label(of tb.Recipient, lang, text string) string {
+	return e.decorate(lang, text, e.IsChecked(of))
+}
+
+/*
+	Decorates a label of a stateful node with marks of a specified locale
+*/
+func (e *Node) decorate(lang, text string, checked bool) string {
+	marks := e.flow.GetMarks(lang)
+	switch e.kind {
+	case kindToggle:
+		if checked {
+			return text + ": " + marks.ToggleOn
+		}
+		return text + ": " + marks.ToggleOff
+	case kindCheckbox:
+		if checked {
+			return marks.CheckboxOn + " " + text
+		}
+		return marks.CheckboxOff + " " + text
+	case kindRadio:
+		if checked {
+			return marks.RadioOn + " " + text
+		}
+		return marks.RadioOff + " " + text
+	}
+	return text
+}
+
+/*
+	Handler for stateful nodes that flips the state and refreshes the page
+*/
+func (e *Node) handleState(c *tb.Callback) {
+	checked := true
+	if e.kind != kindRadio {
+		checked = !e.IsChecked(c.Sender)
+	} else if e.IsChecked(c.Sender) {
+		// selecting the same radio node again changes nothing
+		e.flow.respond(c)
+		return
+	}
+	e.SetChecked(c.Sender, checked)
+	if e.onChange != nil {
+		e.flow.release(c, func() { e.onChange(e, c, checked) })
+	}
+	e.flow.respond(c)
+	d, ok := e.flow.dialogOf(c)
+	if !ok {
+		log.Println(c.Sender.ID, "does not exist")
+		return
+	}
+	page := d.Position
+	if page == nil {
+		page = e.prev
+	}
+	e.update(c.Sender, d, page)
+}
@@ -0,0 +1,84 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"sync"
+	"testing"
+)
+
+/*
+	A state store that keeps values in memory
+*/
+type memStore struct {
+	mx     sync.Mutex
+	values map[string]string
+}
+
+func (s *memStore) GetState(user, key string) (string, bool) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	value, ok := s.values[user+"/"+key]
+	return value, ok
+}
+
+func (s *memStore) SetState(user, key, value string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.values == nil {
+		s.values = make(map[string]string)
+	}
+	s.values[user+"/"+key] = value
+}
+
+func TestStateStores(t *testing.T) {
+	user := &tb.User{ID: 1}
+	tests := []struct {
+		name  string
+		store StateStore
+		// whether a value set before the menu is started is kept
+		early bool
+	}{
+		{name: "dialog state", store: nil, early: false},
+		{name: "custom store", store: &memStore{}, early: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _ := newTestMenu(t, nil)
+			f.SetStateStore(tt.store).GetRoot().
+				AddCheckbox("c", nil).
+				AddToggle("t", nil).
+				AddRadio("r1", "g", nil).
+				AddRadio("r2", "g", nil).
+				GetFlow().Build("en")
+			checkbox, _ := f.Find("c")
+			toggle, _ := f.Find("t")
+			r1, _ := f.Find("r1")
+			r2, _ := f.Find("r2")
+
+			checkbox.SetChecked(user, true)
+			if checkbox.IsChecked(user) != tt.early {
+				t.Errorf("checked before the start %v; want %v", !tt.early, tt.early)
+			}
+
+			d := startDialog(t, f, user)
+			for _, e := range []*Node{toggle, r1, r2} {
+				f.handleCallback(newCallback(user, d.Message, e.id))
+			}
+			if !toggle.IsChecked(user) {
+				t.Error("the toggle is not checked")
+			}
+			if r1.IsChecked(user) || !r2.IsChecked(user) {
+				t.Errorf("radio group %v, %v; want only the last one", r1.IsChecked(user), r2.IsChecked(user))
+			}
+
+			// pressing the selected radio node again keeps it
+			f.handleCallback(newCallback(user, d.Message, r2.id))
+			if !r2.IsChecked(user) {
+				t.Error("the selected radio node is unchecked")
+			}
+			if store, ok := tt.store.(*memStore); ok && len(store.values) == 0 {
+				t.Error("the custom store is not used")
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package menu
+
+import (
+	"fmt"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+/*
+	Handler of a time picked by the user
+	Returns the same navigation results as Callback, e.g. Back or Stay
+*/
+type TimeHandler func(e *Node, c *tb.Callback, hour, minute int) int
+
+/*
+	A time picker with separate hour and minute spinners
+	Step is an interval of minutes, zero means 15 minutes
+	A step that does not divide an hour leaves a shorter last interval, e.g. 25 shows 00, 25 and 50
+	Hour and Minute are the time displayed at first
+*/
+type TimePicker struct {
+	Step    int
+	Hour    int
+	Minute  int
+	Handler TimeHandler
+}
+
+/*
+	The default interval of minutes of time pickers
+*/
+const DefaultTimeStep = 15
+
+/*
+	Creates a new time picker node in the flow
+	that opens hour and minute spinners and passes the picked time to the handler of the picker
+*/
+func (f *Menu) NewTimePickerNode(text string, picker *TimePicker) *Node {
+	return newWidgetNode(f, text, picker, f.root)
+}
+
+/*
+	Adds a new time picker node to the current node
+	Returns the current node
+*/
+func (e *Node) AddTimePicker(text string, picker *TimePicker) *Node {
+	e.AddManySub([]*Node{newWidgetNode(e.flow, text, picker, e)})
+	return e
+}
+
+/*
+	Gets the interval of minutes
+*/
+func (t *TimePicker) step() int {
+	if t.Step < 1 || t.Step > 60 {
+		return DefaultTimeStep
+	}
+	return t.Step
+}
+
+/*
+	Gets the time displayed to the user
+*/
+func (t *TimePicker) shown(e *Node, of tb.Recipient) (int, int) {
+	hour, minute := t.Hour, t.Minute
+	if value, ok := e.flow.getState(of.Recipient(), e.stateKey()); ok {
+		fmt.Sscanf(value, "%d:%d", &hour, &minute)
+	}
+	minute = (minute%60 + 60) % 60
+	return (hour%24 + 24) % 24, minute / t.step() * t.step()
+}
+
+/*
+	Gets the amount of minute values an hour is split into
+*/
+func (t *TimePicker) slots() int {
+	return (60 + t.step() - 1) / t.step()
+}
+
+/*
+	Renders the spinners
+*/
+func (t *TimePicker) keyboard(e *Node, of tb.Recipient, lang string) [][]tb.InlineButton {
+	hour, minute := t.shown(e, of)
+	return [][]tb.InlineButton{
+		{e.widgetButton("▲", "h+"), e.widgetButton("▲", "m+")},
+		{e.widgetButton(fmt.Sprintf("%02d", hour), noop), e.widgetButton(fmt.Sprintf("%02d", minute), noop)},
+		{e.widgetButton("▼", "h-"), e.widgetButton("▼", "m-")},
+		{e.widgetButton(e.flow.tr(lang, e.flow.confirmPath), "ok")},
+	}
+}
+
+/*
+	Spins the hours and minutes and passes the picked time to the handler
+	Minutes wrap around without changing the hour
+	and spin through the slots of the step, so steps that do not divide an hour do not get stuck
+*/
+func (t *TimePicker) press(e *Node, c *tb.Callback, arg string) {
+	hour, minute := t.shown(e, c.Sender)
+	switch arg {
+	case "h+":
+		hour = (hour + 1) % 24
+	case "h-":
+		hour = (hour + 23) % 24
+	case "m+":
+		minute = (minute/t.step() + 1) % t.slots() * t.step()
+	case "m-":
+		minute = (minute/t.step() + t.slots() - 1) % t.slots() * t.step()
+	case "ok":
+		result := Stay
+		if t.Handler != nil {
+			e.flow.release(c, func() { result = t.Handler(e, c, hour, minute) })
+		}
+		e.flow.respond(c)
+		e.navigate(c, result)
+		return
+	}
+	e.flow.respond(c)
+	e.flow.setState(c.Sender.Recipient(), e.stateKey(), fmt.Sprintf("%02d:%02d", hour, minute))
+	e.refresh(c)
+}
@@ -0,0 +1,53 @@
+package menu
+
+import (
+	"fmt"
+	tb "gopkg.in/tucnak/telebot.v2"
+	"testing"
+)
+
+func TestTimePicker(t *testing.T) {
+	user := &tb.User{ID: 1}
+	tests := []struct {
+		step    int
+		hour    int
+		minute  int
+		presses []string
+		want    string
+	}{
+		{step: 0, hour: 9, minute: 0, presses: []string{"m+", "m+"}, want: "09:30"},
+		{step: 15, hour: 9, minute: 50, presses: nil, want: "09:45"},
+		{step: 15, hour: 0, minute: 0, presses: []string{"h-", "m-"}, want: "23:45"},
+		{step: 15, hour: 23, minute: 45, presses: []string{"h+", "m+"}, want: "00:00"},
+		{step: 50, hour: 12, minute: 0, presses: []string{"m-"}, want: "12:50"},
+		{step: 50, hour: 12, minute: 0, presses: []string{"m-", "m-"}, want: "12:00"},
+		{step: 25, hour: 12, minute: 0, presses: []string{"m+", "m+", "m+"}, want: "12:00"},
+		{step: 25, hour: 12, minute: 0, presses: []string{"m-"}, want: "12:50"},
+		{step: 7, hour: 12, minute: 56, presses: []string{"m+"}, want: "12:00"},
+		{step: 45, hour: 12, minute: 45, presses: []string{"m+", "m+"}, want: "12:45"},
+		{step: 90, hour: 12, minute: 0, presses: []string{"m+"}, want: "12:15"},
+	}
+	for _, tt := range tests {
+		name := fmt.Sprintf("step %d from %02d:%02d by %v", tt.step, tt.hour, tt.minute, tt.presses)
+		f, _ := newTestMenu(t, map[string]string{"flow/time": "Time"})
+		picked := ""
+		f.GetRoot().AddTimePicker("time", &TimePicker{
+			Step:   tt.step,
+			Hour:   tt.hour,
+			Minute: tt.minute,
+			Handler: func(e *Node, c *tb.Callback, hour, minute int) int {
+				picked = fmt.Sprintf("%02d:%02d", hour, minute)
+				return Stay
+			},
+		}).GetFlow().Build("en")
+		e, _ := f.Find("time")
+		d := startDialog(t, f, user)
+		f.handleCallback(newCallback(user, d.Message, e.id))
+		for _, arg := range append(tt.presses, "ok") {
+			f.handleCallback(newCallback(user, d.Message, e.id+":"+arg))
+		}
+		if picked != tt.want {
+			t.Errorf("%s: picked %q; want %q", name, picked, tt.want)
+		}
+	}
+}
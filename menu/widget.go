@@ -0,0 +1,192 @@
+package menu
+
+import (
+	tb "gopkg.in/tucnak/telebot.v2"
+	"log"
+	"strings"
+)
+
+/*
+	A widget renders the keyboard of its page for every user on the fly
+	and handles the buttons of that keyboard
+	Buttons of a widget carry the node id and an argument, e.g. "12:next"
+*/
+type widget interface {
+	keyboard(e *Node, of tb.Recipient, lang string) [][]tb.InlineButton
+	press(e *Node, c *tb.Callback, arg string)
+}
+
+/*
+	A widget that renders its node as a whole row on the parent page
+*/
+type inliner interface {
+	row(e *Node, of tb.Recipient, label string) []tb.InlineButton
+}
+
+/*
+	A widget that receives text typed by the user while its page is open
+	The callback stands for the text
+*/
+type receiver interface {
+	receive(e *Node, c *tb.Callback, d *Dialog, m *tb.Message)
+}
+
+/*
+	A widget that displays its own caption while its page is open
+*/
+type captioner interface {
+	caption(e *Node, of tb.Recipient, lang string) string
+}
+
+/*
+	A widget that prepares the dialog before its page is opened
+*/
+type opener interface {
+	open(e *Node, c *tb.Callback, d *Dialog)
+}
+
+const (
+	// an argument of widget buttons that do nothing, e.g. headers and blanks
+	noop = "-"
+	// a prefix of user state keys that hold values of widgets
+	widgetPrefix = "_widget_"
+)
+
+/*
+	Creates a new widget node
+	Only internal use is intended
+*/
+func newWidgetNode(root *Menu, text string, w widget, prev *Node) *Node {
+	e := newNode(root, text, nil, prev)
+	e.kind = kindWidget
+	e.widget = w
+	return e
+}
+
+/*
+	Displays the own caption of a live or widget page instead of the caption
+	The caption is restored once the user leaves the page
+	Only internal use is intended
+*/
+func (f *Menu) pageCaption(d *Dialog, page *Node, of tb.Recipient) {
+	var text string
+	if page.live != nil && page.live.caption != nil {
+		text = page.live.caption(page, of)
+	} else if c, ok := page.widget.(captioner); ok {
+		text = c.caption(page, of, d.Language)
+	}
+	if text == "" {
+		return
+	}
+	if d.question == "" || d.Message.Text != d.question {
+		d.caption = d.Message.Text
+	}
+	d.question = text
+	d.Message.Text = text
+}
+
+/*
+	Checks if the node renders its page on the fly
+*/
+func (e *Node) IsWidget() bool {
+	return e.widget != nil
+}
+
+/*
+	Creates a widget button that passes an argument to the widget of the node
+*/
+func (e *Node) widgetButton(text, arg string) tb.InlineButton {
+	return tb.InlineButton{
+		Unique: e.flow.unique(),
+		Text:   text,
+		Data:   e.id + ":" + arg,
+	}
+}
+
+/*
+	Splits callback data into a node id and a widget argument
+*/
+func splitData(data string) (string, string) {
+	if i := strings.Index(data, ":"); i >= 0 {
+		return data[:i], data[i+1:]
+	}
+	return data, ""
+}
+
+/*
+	Handler for widget nodes that opens the widget page
+*/
+func (e *Node) handleWidget(c *tb.Callback) {
+	e.flow.respond(c)
+	d, ok := e.flow.dialogOf(c)
+	if !ok {
+		log.Println(c.Sender.ID, "does not exist")
+		return
+	}
+	if o, ok := e.widget.(opener); ok {
+		o.open(e, c, d)
+	}
+	from := d.Position
+	if e.update(c.Sender, d, e) {
+		e.flow.remember(d, from, e)
+	} else {
+		e.restore(c)
+	}
+}
+
+/*
+	Passes a press of a widget button to the widget
+	Clicks of users that are not allowed to press the node or any page above it are rejected
+*/
+func (e *Node) pressWidget(c *tb.Callback, arg string) {
+	if b := e.blocked(c.Sender, nil); b != nil {
+		b.deny(c)
+		return
+	}
+	if arg == noop {
+		e.flow.respond(c)
+		return
+	}
+	if arg == clearQuery {
+		e.setQuery(c.Sender, "")
+		e.flow.respond(c)
+		e.refresh(c)
+		return
+	}
+	e.widget.press(e, c, arg)
+}
+
+/*
+	A key of the user state that holds a widget value of the node
+*/
+func (e *Node) stateKey() string {
+	return widgetPrefix + e.id
+}
+
+/*
+	Redraws the page the node was pressed on
+*/
+func (e *Node) redraw(c *tb.Callback) {
+	d, ok := e.flow.dialogOf(c)
+	if !ok {
+		log.Println(c.Sender.ID, "does not exist")
+		return
+	}
+	page := d.Position
+	if page == nil {
+		page = e.prev
+	}
+	e.update(c.Sender, d, page)
+}
+
+/*
+	Redraws the widget page for the user
+*/
+func (e *Node) refresh(c *tb.Callback) {
+	d, ok := e.flow.dialogOf(c)
+	if !ok {
+		log.Println(c.Sender.ID, "does not exist")
+		return
+	}
+	e.update(c.Sender, d, e)
+}